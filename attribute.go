@@ -0,0 +1,458 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AttributeType is the type of value that an attribute holds.
+type AttributeType uint8
+
+const (
+	// AttributeString means that the attribute can hold any text.
+	AttributeString AttributeType = iota
+	// AttributeNumber means that the attribute holds a number.
+	AttributeNumber
+	// AttributeBoolean means that the attribute holds either true or false.
+	AttributeBoolean
+	// AttributeID means that the attribute holds an ID of the form '@0'.
+	AttributeID
+)
+
+var attributeTypeNames = map[AttributeType]string{
+	AttributeString:  "string",
+	AttributeNumber:  "number",
+	AttributeBoolean: "boolean",
+	AttributeID:      "id",
+}
+
+func (t AttributeType) String() string {
+	n, ok := attributeTypeNames[t]
+	if !ok {
+		return "unknown"
+	}
+	return n
+}
+
+// ParseAttributeType parses the name of an attribute type.
+func ParseAttributeType(s string) (AttributeType, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	for t, n := range attributeTypeNames {
+		if n == s {
+			return t, nil
+		}
+	}
+	return AttributeString, fmt.Errorf("unknown attribute type: %s", s)
+}
+
+// Validate returns an error if the value can't be stored in an attribute of this type.
+func (t AttributeType) Validate(value string) error {
+	var err error
+	switch t {
+	case AttributeNumber:
+		_, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+	case AttributeBoolean:
+		_, err = strconv.ParseBool(strings.TrimSpace(value))
+	case AttributeID:
+		_, err = ParseID(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%q is not a valid %s", value, t)
+	}
+	return nil
+}
+
+// AttributeFlags control who can see and change an attribute.
+type AttributeFlags uint8
+
+const (
+	// AttributeVisible means that anybody can see the attribute.
+	AttributeVisible AttributeFlags = 1 << iota
+	// AttributeLocked means that only the player who set the attribute can change it.
+	AttributeLocked
+	// AttributeNoInherit means that children of the object don't inherit the attribute.
+	AttributeNoInherit
+	// AttributeWizard means that only admins can change the attribute.
+	AttributeWizard
+)
+
+var attributeFlagNames = map[AttributeFlags]string{
+	AttributeVisible:   "visible",
+	AttributeLocked:    "locked",
+	AttributeNoInherit: "no-inherit",
+	AttributeWizard:    "wizard",
+}
+
+func (f AttributeFlags) String() string {
+	names := make([]string, 0)
+	for flag, n := range attributeFlagNames {
+		if f&flag != 0 {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+// ParseAttributeFlag parses the name of an attribute flag.
+func ParseAttributeFlag(s string) (AttributeFlags, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	for f, n := range attributeFlagNames {
+		if n == s || strings.Replace(n, "-", "", -1) == s {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown attribute flag: %s", s)
+}
+
+// AttributeInfo holds the type, flags, and setter of an attribute.
+type AttributeInfo struct {
+	Type   AttributeType
+	Flags  AttributeFlags
+	Setter IDType
+}
+
+// attributeName normalizes an attribute name.
+// It returns an error if the name isn't valid.
+func attributeName(s string) (string, error) {
+	n := strings.ToUpper(strings.TrimSpace(s))
+	if n == "" {
+		return "", fmt.Errorf("missing attribute name")
+	}
+	for _, r := range n {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && !strings.ContainsRune("_-.", r) {
+			return "", fmt.Errorf("invalid attribute name: %s", s)
+		}
+	}
+	return n, nil
+}
+
+// lookupAttribute finds an attribute by name, ignoring case.
+func lookupAttribute(values map[string]string, name string) (string, bool) {
+	k, ok := attributeKey(values, name)
+	return values[k], ok
+}
+
+// attributeKey returns the key that an attribute is stored under, ignoring case.
+func attributeKey(values map[string]string, name string) (string, bool) {
+	if _, ok := values[strings.ToUpper(name)]; ok {
+		return strings.ToUpper(name), true
+	}
+	for k := range values {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// attributeMaps returns the attribute values and information for the given thing.
+// The maps are created if they don't exist yet.
+func attributeMaps(t Object) (map[string]string, map[string]AttributeInfo) {
+	if t == nil {
+		return nil, nil
+	}
+	return t.ObjectAttributes()
+}
+
+// canReadAttribute returns true if the player can see the given attribute.
+func (c *Connection) canReadAttribute(t Object, info AttributeInfo) bool {
+	return info.Flags&AttributeVisible != 0 || c.canEdit(t, "attributes")
+}
+
+// canWriteAttribute returns true if the player can change the given attribute.
+func (c *Connection) canWriteAttribute(t Object, info AttributeInfo) bool {
+	if !c.canEdit(t, "attributes") {
+		return false
+	}
+	if c.IsAdmin() {
+		return true
+	}
+	if info.Flags&AttributeWizard != 0 {
+		return false
+	}
+	if info.Flags&AttributeLocked != 0 && info.Setter != c.Player.ID {
+		return false
+	}
+	return true
+}
+
+// splitAttributeTarget splits a string of the form "<target>/<attribute>".
+func splitAttributeTarget(s string) (target string, attr string) {
+	i := strings.LastIndex(s, "/")
+	if i < 0 {
+		return strings.TrimSpace(s), ""
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+}
+
+// SetAttribute executes the "&<attribute> <target>=<value>" command.
+// An empty value removes the attribute.
+func (c *Connection) SetAttribute(name string, target string, value string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	n, err := attributeName(name)
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	c.setAttribute(t, n, value)
+}
+
+// setAttribute sets an attribute on a thing. An empty value removes the attribute.
+func (c *Connection) setAttribute(t Object, n string, value string) {
+	values, infos := attributeMaps(t)
+	info := infos[n]
+	if values == nil || !c.canWriteAttribute(t, info) {
+		c.Printf("Can't set %s on %s.\n", n, t)
+		return
+	}
+	if value == "" {
+		c.changed(t, "attribute", "&"+n, values[n], "")
+		delete(values, n)
+		delete(infos, n)
+		c.Printf("%s cleared.\n", n)
+		return
+	}
+	err := info.Type.Validate(value)
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	info.Setter = c.Player.ID
+	c.changed(t, "attribute", "&"+n, values[n], value)
+	values[n] = value
+	infos[n] = info
+	c.Printf("Set.\n")
+}
+
+// GetAttributes executes the "@get <target>[/<attribute>]" command.
+func (c *Connection) GetAttributes(arg string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	target, pattern := splitAttributeTarget(arg)
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	s := ""
+	shown := make(map[string]bool)
+	for _, x := range append([]Object{t}, c.ancestors(t)...) {
+		values, infos := attributeMaps(x)
+		for _, k := range sortedAttributeNames(values, pattern) {
+			info := infos[k]
+			if shown[strings.ToUpper(k)] {
+				continue
+			}
+			if x != t && info.Flags&AttributeNoInherit != 0 {
+				continue
+			}
+			// An attribute that can't be read still hides the ones it overrides.
+			shown[strings.ToUpper(k)] = true
+			if !c.canReadAttribute(x, info) {
+				continue
+			}
+			s += fmt.Sprintf("%s: %s\n", k, values[k])
+		}
+	}
+	if s == "" {
+		c.Printf("No matching attributes.\n")
+		return
+	}
+	c.Printf("%s\n", s)
+}
+
+// WipeAttributes executes the "@wipe <target>[/<pattern>]" command, which removes attributes from a target.
+// Attributes the player isn't allowed to change are left alone.
+func (c *Connection) WipeAttributes(arg string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	target, pattern := splitAttributeTarget(arg)
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	if !c.canEdit(t, "attributes") {
+		c.Printf("Can't wipe attributes on %s.\n", t)
+		return
+	}
+	values, infos := attributeMaps(t)
+	count := 0
+	for _, k := range sortedAttributeNames(values, pattern) {
+		if c.canWriteAttribute(t, infos[k]) {
+			c.changed(t, "attribute", "&"+k, values[k], "")
+			delete(values, k)
+			delete(infos, k)
+			count++
+		}
+	}
+	c.Printf("Wiped %d attribute(s).\n", count)
+}
+
+// SetAttributeFlags executes the "@aflag <target>/<attribute>=[!]<flag> ..." command.
+func (c *Connection) SetAttributeFlags(arg string, flags string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	target, name := splitAttributeTarget(arg)
+	n, err := attributeName(name)
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	values, infos := attributeMaps(t)
+	info := infos[n]
+	if _, ok := values[n]; !ok {
+		c.Printf("%s doesn't have a %s attribute.\n", t, n)
+		return
+	}
+	if !c.canWriteAttribute(t, info) {
+		c.Printf("Can't change %s on %s.\n", n, t)
+		return
+	}
+	for _, f := range strings.Fields(flags) {
+		clear := strings.HasPrefix(f, "!")
+		flag, err := ParseAttributeFlag(strings.TrimPrefix(f, "!"))
+		if err != nil {
+			c.Printf("Error: %s\n", err.Error())
+			return
+		}
+		if flag == AttributeWizard && !c.IsAdmin() {
+			c.Printf("Only admins can change the wizard flag.\n")
+			return
+		}
+		if clear {
+			info.Flags &^= flag
+		} else {
+			info.Flags |= flag
+		}
+	}
+	c.Audit("attribute flags", t.ObjectID(), "&"+n, infos[n].Flags.String(), info.Flags.String())
+	infos[n] = info
+	c.Printf("%s flags: %s\n", n, info.Flags)
+}
+
+// SetAttributeType executes the "@atype <target>/<attribute>=<type>" command.
+func (c *Connection) SetAttributeType(arg string, typeName string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	target, name := splitAttributeTarget(arg)
+	n, err := attributeName(name)
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	at, err := ParseAttributeType(typeName)
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	values, infos := attributeMaps(t)
+	info := infos[n]
+	v, ok := values[n]
+	if !ok {
+		c.Printf("%s doesn't have a %s attribute.\n", t, n)
+		return
+	}
+	if !c.canWriteAttribute(t, info) {
+		c.Printf("Can't change %s on %s.\n", n, t)
+		return
+	}
+	err = at.Validate(v)
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	c.Audit("attribute type", t.ObjectID(), "&"+n, info.Type.String(), at.String())
+	info.Type = at
+	infos[n] = info
+	c.Printf("%s is now a %s.\n", n, at)
+}
+
+// sortedAttributeNames returns the sorted names of the attributes that match the given wildcard pattern.
+// An empty pattern matches every attribute.
+func sortedAttributeNames(values map[string]string, pattern string) []string {
+	p := strings.ToUpper(pattern)
+	names := make([]string, 0, len(values))
+	for k := range values {
+		if p != "" {
+			if ok, _ := path.Match(p, strings.ToUpper(k)); !ok {
+				continue
+			}
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// showAttributes formats a thing's attributes for the "show" command.
+// Attributes inherited from the thing's ancestors are included.
+func (c *Connection) showAttributes(t Object) string {
+	s := fmt.Sprintf("%15s : %s\n", "Attributes", "")
+	shown := make(map[string]bool)
+	for _, x := range append([]Object{t}, c.ancestors(t)...) {
+		values, infos := attributeMaps(x)
+		for _, k := range sortedAttributeNames(values, "") {
+			info := infos[k]
+			if shown[strings.ToUpper(k)] {
+				continue
+			}
+			if x != t && info.Flags&AttributeNoInherit != 0 {
+				continue
+			}
+			// An attribute that can't be read still hides the ones it overrides.
+			shown[strings.ToUpper(k)] = true
+			if !c.canReadAttribute(x, info) {
+				continue
+			}
+			s += fmt.Sprintf("                  %15s : %q [%s", k, values[k], info.Type)
+			if info.Flags != 0 {
+				s += " " + info.Flags.String()
+			}
+			if x != t {
+				s += fmt.Sprintf(", inherited from %s", x)
+			}
+			s += "]\n"
+		}
+	}
+	return s
+}
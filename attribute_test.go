@@ -0,0 +1,70 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+type testPairAttributeType struct {
+	t AttributeType
+	v string
+	e bool
+}
+
+var attributeTypeTests = []testPairAttributeType{
+	{AttributeString, "anything at all", false},
+	{AttributeNumber, "42", false},
+	{AttributeNumber, "-3.5", false},
+	{AttributeNumber, "forty-two", true},
+	{AttributeBoolean, "true", false},
+	{AttributeBoolean, "yes", true},
+	{AttributeID, "@12", false},
+	{AttributeID, "12", true},
+}
+
+// TestAttributeType tests validating attribute values against their types.
+func TestAttributeType(t *testing.T) {
+	for _, x := range attributeTypeTests {
+		err := x.t.Validate(x.v)
+		if err != nil && !x.e {
+			t.Errorf("%s.Validate(%q) threw an error when it shouldn't have.", x.t, x.v)
+		} else if err == nil && x.e {
+			t.Errorf("%s.Validate(%q) didn't throw an error when it should have.", x.t, x.v)
+		}
+	}
+}
+
+// TestAttributeName tests normalizing attribute names.
+func TestAttributeName(t *testing.T) {
+	n, err := attributeName(" color_2 ")
+	if err != nil || n != "COLOR_2" {
+		t.Errorf("attributeName(\" color_2 \") = %q, %v, but we expected \"COLOR_2\".", n, err)
+	}
+	for _, s := range []string{"", "two words", "a/b", "x=y"} {
+		if _, err := attributeName(s); err == nil {
+			t.Errorf("attributeName(%q) didn't throw an error when it should have.", s)
+		}
+	}
+	f, err := ParseAttributeFlag("noinherit")
+	if err != nil || f != AttributeNoInherit {
+		t.Errorf("ParseAttributeFlag(\"noinherit\") = %v, %v, but we expected %v.", f, err, AttributeNoInherit)
+	}
+}
@@ -0,0 +1,303 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// AuditMaxValue is the longest old or new value, in bytes, that is written to the audit log.
+// Longer values are cut short so that one large change can't bloat the log.
+const AuditMaxValue = 4096
+
+// auditMaxLine is the longest line that Query will read from the audit log.
+const auditMaxLine = 1024 * 1024
+
+// truncateAuditValue cuts a value down to AuditMaxValue bytes without splitting a character.
+func truncateAuditValue(s string) string {
+	if len(s) <= AuditMaxValue {
+		return s
+	}
+	const more = "..."
+	n := AuditMaxValue - len(more)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + more
+}
+
+// AuditEntry records a single building or admin action.
+type AuditEntry struct {
+	Time   time.Time
+	Actor  IDType
+	Action string
+	Target IDType
+	Field  string
+	Old    string
+	New    string
+}
+
+func (e AuditEntry) String() string {
+	s := fmt.Sprintf("%s %s %s", e.Time.Format(time.RFC3339), e.Actor, e.Action)
+	if e.Target > 0 {
+		s += " " + e.Target.String()
+	}
+	if e.Field != "" {
+		s += " " + e.Field
+	}
+	if e.Old != "" || e.New != "" {
+		s += fmt.Sprintf(": %q -> %q", e.Old, e.New)
+	}
+	return s
+}
+
+// AuditLog is an append-only log of building and admin actions.
+// Entries are written to a file as JSON, one per line.
+// When the file grows past MaxSize bytes it is rotated, and Keep old files are kept.
+type AuditLog struct {
+	Path    string
+	MaxSize int64
+	Keep    int
+
+	mu sync.Mutex
+}
+
+// NewAuditLog creates an AuditLog using the server's configuration.
+func NewAuditLog(cfg *Config) *AuditLog {
+	return &AuditLog{Path: cfg.AuditFile, MaxSize: cfg.AuditMaxSize, Keep: cfg.AuditKeep}
+}
+
+// rotatedPath returns the name of the n-th rotated log file.
+func (a *AuditLog) rotatedPath(n int) string {
+	return fmt.Sprintf("%s.%d", a.Path, n)
+}
+
+// rotate renames the log files if the current file is too large.
+func (a *AuditLog) rotate() {
+	if a.MaxSize <= 0 {
+		return
+	}
+	info, err := os.Stat(a.Path)
+	if err != nil || info.Size() < a.MaxSize {
+		return
+	}
+	if a.Keep <= 0 {
+		os.Remove(a.Path)
+		return
+	}
+	os.Remove(a.rotatedPath(a.Keep))
+	for n := a.Keep - 1; n > 0; n-- {
+		os.Rename(a.rotatedPath(n), a.rotatedPath(n+1))
+	}
+	os.Rename(a.Path, a.rotatedPath(1))
+}
+
+// Record appends an entry to the log.
+// Old and new values longer than AuditMaxValue are truncated.
+func (a *AuditLog) Record(e AuditEntry) error {
+	if a == nil || a.Path == "" {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rotate()
+	e.Old = truncateAuditValue(e.Old)
+	e.New = truncateAuditValue(e.New)
+	file, err := os.OpenFile(a.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("ERROR: Could not open audit log: %s\n", err.Error())
+		return err
+	}
+	defer file.Close()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(b, '\n'))
+	if err != nil {
+		log.Printf("ERROR: Could not write audit log: %s\n", err.Error())
+	}
+	return err
+}
+
+// Query returns the entries that match the filter, oldest first.
+// Rotated log files are searched as well.
+func (a *AuditLog) Query(match func(AuditEntry) bool) ([]AuditEntry, error) {
+	r := make([]AuditEntry, 0)
+	if a == nil || a.Path == "" {
+		return r, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	files := []string{a.Path}
+	for n := 1; n <= a.Keep; n++ {
+		files = append([]string{a.rotatedPath(n)}, files...)
+	}
+	for _, fn := range files {
+		file, err := os.Open(fn)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return r, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), auditMaxLine)
+		for scanner.Scan() {
+			var e AuditEntry
+			if json.Unmarshal(scanner.Bytes(), &e) == nil && match(e) {
+				r = append(r, e)
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// Audit records an action taken by the player in the audit log.
+func (c *Connection) Audit(action string, target IDType, field string, oldValue string, newValue string) {
+	if c == nil || c.Server == nil || c.Player == nil {
+		return
+	}
+	c.Server.Audit.Record(AuditEntry{
+		Time:   time.Now(),
+		Actor:  c.Player.ID,
+		Action: action,
+		Target: target,
+		Field:  field,
+		Old:    oldValue,
+		New:    newValue,
+	})
+}
+
+// fieldAliases maps the short field names accepted by the "set" command to struct fields.
+var fieldAliases = map[string]string{
+	"desc":            "Description",
+	"long":            "LongDescription",
+	"arrive":          "ArriveMessage",
+	"leave":           "LeaveMessage",
+	"dest":            "Destination",
+	"fail":            "Lock.FailMessage",
+	"failmessage":     "Lock.FailMessage",
+	"roomfail":        "Lock.RoomFailMessage",
+	"roomfailmessage": "Lock.RoomFailMessage",
+	"attached":        "Flags",
+	"hidden":          "Flags",
+}
+
+// fieldValue returns the value of a field on a thing as a string, or "" if there is no such field.
+// Field names are matched without regard to case.
+func fieldValue(t Object, field string) string {
+	name := strings.TrimSpace(strings.ToLower(field))
+	if alias, ok := fieldAliases[name]; ok {
+		name = alias
+	}
+	v := reflect.ValueOf(t)
+	for _, part := range strings.Split(name, ".") {
+		for v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return ""
+		}
+		p := part
+		v = v.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, p) })
+		if !v.IsValid() {
+			return ""
+		}
+	}
+	switch x := v.Interface().(type) {
+	case []string:
+		return strings.Join(x, ";")
+	case []IDType:
+		return showIDs(x)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// parseSince parses a time given as a duration before now, such as "24h", or as a date.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, f := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(f, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't parse time: %s", s)
+}
+
+// auditQueryLimit is the most entries that "@audit" will show.
+const auditQueryLimit = 50
+
+// ShowAudit executes the "@audit [<target>|<player>] [<since>]" command.
+// A target matches entries about that thing, and a player also matches entries about things they did.
+func (c *Connection) ShowAudit(args []string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	var id IDType
+	var since time.Time
+	for _, arg := range args {
+		if t, err := parseSince(arg, time.Now()); err == nil {
+			since = t
+		} else if x, err := ParseID(arg); err == nil {
+			id = x
+		} else if p := c.lookupPlayer(arg); p != nil {
+			id = p.ID
+		} else {
+			c.Printf("%s is not a player, an ID, or a time.\n", arg)
+			return
+		}
+	}
+	entries, err := c.Server.Audit.Query(func(e AuditEntry) bool {
+		return (id == 0 || e.Target == id || e.Actor == id) && !e.Time.Before(since)
+	})
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		c.Printf("No matching audit entries.\n")
+		return
+	}
+	if len(entries) > auditQueryLimit {
+		c.Printf("Showing the last %d of %d entries.\n", auditQueryLimit, len(entries))
+		entries = entries[len(entries)-auditQueryLimit:]
+	}
+	s := ""
+	for _, e := range entries {
+		s += e.String() + "\n"
+	}
+	c.Printf("%s\n", s)
+}
@@ -0,0 +1,118 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a := &AuditLog{Path: path.Join(dir, "audit.log"), MaxSize: 1, Keep: 2}
+	for i := 1; i <= 4; i++ {
+		if err := a.Record(AuditEntry{Actor: IDType(i), Action: "set"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := a.Query(func(e AuditEntry) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every entry is rotated into its own file, and only the newest three files are kept.
+	if len(entries) != 3 || entries[0].Actor != 2 || entries[2].Actor != 4 {
+		t.Errorf("Query() = %v, but we expected the last three entries in order.", entries)
+	}
+}
+
+func TestAuditLogLongValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a := &AuditLog{Path: path.Join(dir, "audit.log")}
+	long := strings.Repeat("é", 100*1024)
+	a.Record(AuditEntry{Actor: 1, Action: "set", New: long})
+	a.Record(AuditEntry{Actor: 2, Action: "set"})
+	// Lines that were written before values were truncated can still be read.
+	file, err := os.OpenFile(a.Path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(file, "{\"Actor\":3,\"Action\":\"set\",\"Old\":%q}\n", long)
+	file.Close()
+
+	entries, err := a.Query(func(e AuditEntry) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Query() returned %d entries, but we expected 3.", len(entries))
+	}
+	if v := entries[0].New; len(v) > AuditMaxValue || !utf8.ValidString(v) || !strings.HasSuffix(v, "...") {
+		t.Errorf("Record() wrote a %d byte value, but we expected it to be truncated to %d bytes.", len(v), AuditMaxValue)
+	}
+	if entries[2].Old != long {
+		t.Errorf("Query() didn't read back a long line.")
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	e := &Exit{Name: "north", Aliases: []string{"n", "fwd"}, Destination: 5, Lock: Lock{FailMessage: "No."}}
+	tests := map[string]string{
+		"name":        "north",
+		"ALIASES":     "n;fwd",
+		"dest":        "@5",
+		"fail":        "No.",
+		"nonexistent": "",
+	}
+	for field, want := range tests {
+		if v := fieldValue(e, field); v != want {
+			t.Errorf("fieldValue(%q) = %q, but we expected %q.", field, v, want)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"24h":        now.Add(-24 * time.Hour),
+		"2017-05-01": time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for s, want := range tests {
+		if v, err := parseSince(s, now); err != nil || !v.Equal(want) {
+			t.Errorf("parseSince(%q) = %s, %v, but we expected %s.", s, v, err, want)
+		}
+	}
+	if _, err := parseSince("@5", now); err == nil {
+		t.Errorf("parseSince(%q) didn't throw an error.", "@5")
+	}
+}
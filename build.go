@@ -0,0 +1,133 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseExitSpec parses an exit specification of the form "name;alias;alias".
+func parseExitSpec(spec string) (name string, aliases []string) {
+	parts := strings.SplitN(spec, ";", 2)
+	name = strings.TrimSpace(parts[0])
+	if len(parts) > 1 {
+		aliases = parseAliases(parts[1])
+	} else {
+		aliases = make([]string, 0)
+	}
+	return name, aliases
+}
+
+// parseAliases parses a list of aliases of the form "alias;alias;alias".
+func parseAliases(s string) []string {
+	aliases := make([]string, 0)
+	for _, a := range strings.Split(s, ";") {
+		a = strings.TrimSpace(a)
+		if a != "" {
+			aliases = append(aliases, a)
+		}
+	}
+	return aliases
+}
+
+// Dig executes the "@dig" command, which creates a new room and, optionally, exits leading to and from it.
+// The command has the form "<room name>=<exit>;<aliases>,<return exit>;<aliases>".
+func (c *Connection) Dig(args string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	parts := strings.SplitN(args, "=", 2)
+	roomName := strings.TrimSpace(parts[0])
+	if roomName == "" {
+		c.Printf("You need to give the room a name.\n")
+		return
+	}
+	var exitName, returnName string
+	var exitAliases, returnAliases []string
+	if len(parts) > 1 {
+		specs := strings.SplitN(parts[1], ",", 2)
+		exitName, exitAliases = parseExitSpec(specs[0])
+		if len(specs) > 1 {
+			returnName, returnAliases = parseExitSpec(specs[1])
+		}
+	}
+
+	var here *Room
+	if exitName != "" || returnName != "" {
+		if c.Player.Location.Type == LocationRoom {
+			here = c.FindRoomByID(c.Player.Location.ID)
+		}
+		if here == nil {
+			c.Printf("You need to be in a room to dig exits.\n")
+			return
+		}
+		if exitName != "" && !c.CanEditRoom(here, "exits") {
+			c.Printf("You don't have permission to add exits to %s.\n", here)
+			return
+		}
+	}
+
+	exits := 0
+	if exitName != "" {
+		exits++
+	}
+	if returnName != "" {
+		exits++
+	}
+	if !c.checkQuota(map[ObjectType]int{ObjectRoom: 1, ObjectExit: exits}) {
+		return
+	}
+
+	r := c.NewRoom(roomName, "")
+	if r == nil {
+		c.Println("Couldn't Create Room")
+		return
+	}
+	c.Printf("New Room Created: %s\n", r.String())
+
+	if exitName != "" {
+		e := c.NewExitInRoom(here.ID, exitName, fmt.Sprintf("A passage leads to %s.", r.Name))
+		if e == nil {
+			c.Println("Couldn't Create Exit")
+		} else {
+			c.linkExit(e, exitAliases, here, r)
+			c.Printf("New Exit Created: %s\n", e.String())
+		}
+	}
+
+	if returnName != "" {
+		e := c.NewExitInRoom(r.ID, returnName, fmt.Sprintf("A passage leads to %s.", here.Name))
+		if e == nil {
+			c.Println("Couldn't Create Exit")
+		} else {
+			c.linkExit(e, returnAliases, r, here)
+			c.Printf("New Exit Created: %s\n", e.String())
+		}
+	}
+}
+
+// linkExit points a newly created exit at its destination and gives it default arrive and leave messages.
+func (c *Connection) linkExit(e *Exit, aliases []string, from *Room, to *Room) {
+	e.Aliases = aliases
+	e.Destination = to.ID
+	e.LeaveMessage = fmt.Sprintf("%%s goes %s.", escapeFormat(e.Name))
+	e.ArriveMessage = fmt.Sprintf("%%s arrives from %s.", escapeFormat(from.Name))
+}
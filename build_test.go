@@ -0,0 +1,78 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+// findRoomByName returns the room in the world with the given name.
+func findRoomByName(w *World, name string) *Room {
+	for _, r := range w.db.Rooms {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func TestDig(t *testing.T) {
+	w := NewWorld()
+	hall := &Room{ID: w.nextID(), Name: "Hall"}
+	w.db.Rooms[hall.ID] = hall
+	p := &Player{ID: w.nextID(), Role: RoleBuilder, Location: Location{ID: hall.ID, Type: LocationRoom}}
+	hall.Owner = p.ID
+	w.db.Players[p.ID] = p
+	c := testConnection(testServer(t, w), p)
+
+	c.Dig("Vault")
+	vault := findRoomByName(w, "Vault")
+	if vault == nil {
+		t.Fatalf("Dig didn't create the vault.")
+	}
+	if len(hall.Exits) != 0 || len(vault.Exits) != 0 {
+		t.Errorf("Dig without exits created %d exits in the hall and %d in the vault.", len(hall.Exits), len(vault.Exits))
+	}
+
+	c.Dig("Attic=up;u;climb")
+	attic := findRoomByName(w, "Attic")
+	if attic == nil {
+		t.Fatalf("Dig didn't create the attic.")
+	}
+	if len(hall.Exits) != 1 || len(attic.Exits) != 0 {
+		t.Fatalf("Dig with one exit created %d exits in the hall and %d in the attic, but we expected 1 and 0.", len(hall.Exits), len(attic.Exits))
+	}
+	up := hall.Exits[0]
+	if up.Name != "up" || up.Destination != attic.ID || up.Owner != p.ID || len(up.Aliases) != 2 {
+		t.Errorf("Dig created exit %q to %s owned by %s with aliases %v.", up.Name, up.Destination, up.Owner, up.Aliases)
+	}
+
+	c.Dig("Garden=out;o,in;i")
+	garden := findRoomByName(w, "Garden")
+	if garden == nil {
+		t.Fatalf("Dig didn't create the garden.")
+	}
+	if len(hall.Exits) != 2 || len(garden.Exits) != 1 {
+		t.Fatalf("Dig with a return exit created %d exits in the hall and %d in the garden, but we expected 2 and 1.", len(hall.Exits), len(garden.Exits))
+	}
+	if in := garden.Exits[0]; in.Name != "in" || in.Room != garden.ID || in.Destination != hall.ID {
+		t.Errorf("Dig created return exit %q in %s leading to %s.", in.Name, in.Room, in.Destination)
+	}
+}
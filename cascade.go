@@ -0,0 +1,236 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"log"
+	"sort"
+)
+
+// Cascade describes everything else that changed when a room or player was destroyed.
+type Cascade struct {
+	// Fallback is the room that occupants and contents were moved to.
+	Fallback IDType
+	// Players and Items were moved to the fallback room.
+	Players []*Player
+	Items   []*Item
+	// Exits led into a destroyed room, or belonged to a destroyed player, and were moved into the trash.
+	Exits []*Exit
+	// Transferred were given to the destroyed player's heir, and Destroyed were moved into the trash.
+	Transferred []Object
+	Destroyed   []Object
+}
+
+// fallbackRoom returns the room that things are moved to when the room they are in is destroyed.
+// DefaultRoom is used if the fallback room doesn't exist or is being destroyed.
+// It must only be called from WorldThread.
+func (w *World) fallbackRoom(fallback IDType, destroying IDType) IDType {
+	if fallback == 0 || fallback == destroying || w.db.Rooms[fallback] == nil {
+		return w.db.DefaultRoom
+	}
+	return fallback
+}
+
+// destroyRoom moves a room into the trash along with its own exits.
+// Exits in other rooms that lead into it are also moved into the trash,
+// and the players and items inside it are moved to the fallback room.
+// It returns nil if the room can't be destroyed.
+// It must only be called from WorldThread.
+func (w *World) destroyRoom(id IDType, by IDType, fallback IDType) *Cascade {
+	r := w.db.Rooms[id]
+	if r == nil || id == w.db.DefaultRoom {
+		return nil
+	}
+	log.Printf("Destroy Room: %d\n", id)
+	c := &Cascade{Fallback: w.fallbackRoom(fallback, id)}
+	delete(w.db.Rooms, id)
+	w.trash(&Trash{DestroyedBy: by, Room: r})
+	for _, other := range w.db.Rooms {
+		exits := make([]*Exit, 0, len(other.Exits))
+		for _, e := range other.Exits {
+			if e != nil && e.Destination == id {
+				w.trash(&Trash{DestroyedBy: by, Exit: e})
+				c.Exits = append(c.Exits, e)
+				continue
+			}
+			exits = append(exits, e)
+		}
+		other.Exits = exits
+	}
+	w.rehome(c)
+	return c
+}
+
+// destroyPlayer removes a player and their password and two-factor secret.
+// If the heir exists, the rooms, exits, items, and zones that the player owned are given to the heir.
+// Otherwise they are moved into the trash, and their zones are left without an owner.
+// Either way, things that the player was carrying and anybody inside their rooms are moved to the fallback room.
+// Gods can't be destroyed, so it returns nil for them or players who don't exist.
+// It must only be called from WorldThread.
+func (w *World) destroyPlayer(id IDType, by IDType, heir IDType, fallback IDType) *Cascade {
+	p := w.db.Players[id]
+	if p == nil || p.Role == RoleGod {
+		return nil
+	}
+	if heir == id || w.db.Players[heir] == nil {
+		heir = 0
+	}
+	log.Printf("Destroy Player: %d\n", id)
+	c := &Cascade{Fallback: w.fallbackRoom(fallback, 0)}
+	delete(w.db.Players, id)
+	delete(w.db.Auth, id)
+	delete(w.db.TOTP, id)
+
+	rooms := make([]*Room, 0)
+	for _, r := range w.db.Rooms {
+		if r.Owner == id {
+			rooms = append(rooms, r)
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	for _, r := range rooms {
+		if heir != 0 {
+			r.Owner = heir
+			c.Transferred = append(c.Transferred, r)
+		} else if x := w.destroyRoom(r.ID, by, c.Fallback); x != nil {
+			c.Destroyed = append(c.Destroyed, r)
+			c.Exits = append(c.Exits, x.Exits...)
+			c.Players = append(c.Players, x.Players...)
+			c.Items = append(c.Items, x.Items...)
+		} else {
+			// DefaultRoom can't be destroyed, so it is left without an owner.
+			r.Owner = 0
+		}
+	}
+
+	for _, r := range w.db.Rooms {
+		exits := make([]*Exit, 0, len(r.Exits))
+		for _, e := range r.Exits {
+			if e != nil && e.Owner == id {
+				if heir != 0 {
+					e.Owner = heir
+					c.Transferred = append(c.Transferred, e)
+				} else {
+					w.trash(&Trash{DestroyedBy: by, Exit: e})
+					c.Destroyed = append(c.Destroyed, e)
+					continue
+				}
+			}
+			exits = append(exits, e)
+		}
+		r.Exits = exits
+		r.Editors = removeID(r.Editors, id)
+		for _, e := range r.Exits {
+			if e != nil {
+				e.Editors = removeID(e.Editors, id)
+			}
+		}
+	}
+
+	for _, i := range w.db.Items {
+		if i.Owner == id {
+			if heir != 0 {
+				i.Owner = heir
+				c.Transferred = append(c.Transferred, i)
+			} else {
+				delete(w.db.Items, i.ID)
+				w.trash(&Trash{DestroyedBy: by, Item: i})
+				c.Destroyed = append(c.Destroyed, i)
+				continue
+			}
+		}
+		i.Editors = removeID(i.Editors, id)
+	}
+
+	for _, z := range w.db.Zones {
+		if z.Owner == id {
+			z.Owner = heir
+		}
+		z.Builders = removeID(z.Builders, id)
+	}
+	w.rehome(c)
+	return c
+}
+
+// rehome moves players and items whose location no longer exists to the fallback room.
+// It must only be called from WorldThread.
+func (w *World) rehome(c *Cascade) {
+	to := Location{ID: c.Fallback, Type: LocationRoom}
+	for _, p := range w.db.Players {
+		if !w.locationExists(p.Location) {
+			p.Location = to
+			c.Players = append(c.Players, p)
+		}
+	}
+	// Moving a container can't strand anything, so one pass is enough.
+	for _, i := range w.db.Items {
+		if !w.locationExists(i.Location) {
+			i.Location = to
+			c.Items = append(c.Items, i)
+		}
+	}
+}
+
+// removeID returns a copy of a list of IDs without the given ID.
+func removeID(ids []IDType, id IDType) []IDType {
+	if len(ids) == 0 {
+		return ids
+	}
+	r := make([]IDType, 0, len(ids))
+	for _, x := range ids {
+		if x != id {
+			r = append(r, x)
+		}
+	}
+	return r
+}
+
+// notifyCascade tells the players who are online about the things that happened to them when something was destroyed.
+func (c *Connection) notifyCascade(what string, x *Cascade) {
+	if x == nil {
+		return
+	}
+	for _, p := range x.Players {
+		for _, conn := range c.Server.PlayerConnections() {
+			if conn.Player != nil && conn.Authenticated && conn.Player.ID == p.ID {
+				conn.Printf("%s has been destroyed. You find yourself somewhere else.\n", what)
+				conn.Look("")
+			}
+		}
+	}
+	for _, i := range x.Items {
+		c.LocationPrintf(&i.Location, "%s appears suddenly.\n", i.Name)
+	}
+	for _, conn := range c.Server.PlayerConnections() {
+		if conn.Player == nil || !conn.Authenticated || conn.Player.ID == c.Player.ID {
+			continue
+		}
+		for _, e := range x.Exits {
+			if e.Owner == conn.Player.ID {
+				conn.Printf("Your exit %s was removed because %s was destroyed.\n", e, what)
+			}
+		}
+		for _, o := range x.Transferred {
+			if o.ObjectOwner() == conn.Player.ID {
+				conn.Printf("You now own %s %s, which belonged to %s.\n", o.ObjectType(), o, what)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+func TestDestroyRoom(t *testing.T) {
+	w := NewWorld()
+	home := w.db.Rooms[w.db.DefaultRoom]
+	r := &Room{ID: w.nextID()}
+	w.db.Rooms[r.ID] = r
+	in := &Exit{ID: w.nextID(), Room: home.ID, Destination: r.ID}
+	home.Exits = append(home.Exits, in)
+	p := &Player{ID: w.nextID(), Location: Location{ID: r.ID, Type: LocationRoom}}
+	w.db.Players[p.ID] = p
+	box := &Item{ID: w.nextID(), Location: Location{ID: r.ID, Type: LocationRoom}}
+	w.db.Items[box.ID] = box
+
+	if w.destroyRoom(home.ID, p.ID, 0) != nil {
+		t.Errorf("destroyRoom() destroyed the default room.")
+	}
+	x := w.destroyRoom(r.ID, p.ID, 0)
+	if x == nil {
+		t.Fatalf("destroyRoom() didn't destroy the room.")
+	}
+	if w.db.Trash[r.ID] == nil || w.db.Trash[in.ID] == nil || len(x.Exits) != 1 {
+		t.Errorf("The room and the exit leading into it weren't moved into the trash.")
+	}
+	for _, e := range home.Exits {
+		if e == in {
+			t.Errorf("The exit leading into the destroyed room wasn't removed.")
+		}
+	}
+	if p.Location.ID != home.ID || box.Location.ID != home.ID || len(x.Players) != 1 || len(x.Items) != 1 {
+		t.Errorf("The player and item in the room weren't moved to the fallback room.")
+	}
+}
+
+func TestDestroyPlayer(t *testing.T) {
+	w := NewWorld()
+	god := &Player{ID: w.nextID(), Role: RoleGod}
+	w.db.Players[god.ID] = god
+	p := &Player{ID: w.nextID()}
+	w.db.Players[p.ID] = p
+	w.db.Auth[p.ID] = PasswordHash{}
+	heir := &Player{ID: w.nextID()}
+	w.db.Players[heir.ID] = heir
+	r := &Room{ID: w.nextID(), Owner: p.ID, Editors: []IDType{heir.ID}}
+	w.db.Rooms[r.ID] = r
+	box := &Item{ID: w.nextID(), Owner: p.ID, Location: Location{ID: p.ID, Type: LocationPlayer}}
+	w.db.Items[box.ID] = box
+
+	if w.destroyPlayer(god.ID, god.ID, 0, 0) != nil {
+		t.Errorf("destroyPlayer() destroyed a god.")
+	}
+	x := w.destroyPlayer(p.ID, god.ID, heir.ID, 0)
+	if x == nil {
+		t.Fatalf("destroyPlayer() didn't destroy the player.")
+	}
+	if _, ok := w.db.Auth[p.ID]; ok || w.db.Players[p.ID] != nil {
+		t.Errorf("The player or their password wasn't removed.")
+	}
+	if r.Owner != heir.ID || box.Owner != heir.ID || len(x.Transferred) != 2 {
+		t.Errorf("The player's room and item weren't given to the heir.")
+	}
+	if box.Location.ID != w.db.DefaultRoom {
+		t.Errorf("The item that the player was carrying wasn't moved to the fallback room.")
+	}
+
+	p2 := &Player{ID: w.nextID()}
+	w.db.Players[p2.ID] = p2
+	r.Owner = p2.ID
+	if x := w.destroyPlayer(p2.ID, god.ID, 0, 0); x == nil || len(x.Destroyed) != 1 || w.db.Trash[r.ID] == nil {
+		t.Errorf("destroyPlayer() didn't move the room of a player without an heir into the trash.")
+	}
+}
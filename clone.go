@@ -0,0 +1,211 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"log"
+	"strings"
+)
+
+// CloneMessage is sent to Clone to copy an item or a room.
+// If Deep is set, the items inside the original are copied as well.
+// Nothing is copied if the copies would put the owner over their Quota.
+type CloneMessage struct {
+	ID    IDType
+	Owner IDType
+	Deep  bool
+	Quota map[ObjectType]int
+	Ack   chan Object
+}
+
+// copyAttributes returns a copy of an attribute map.
+func copyAttributes(m map[string]string) map[string]string {
+	r := make(map[string]string, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
+}
+
+// copyAttributeInfo returns a copy of an attribute info map.
+func copyAttributeInfo(m map[string]AttributeInfo) map[string]AttributeInfo {
+	if m == nil {
+		return nil
+	}
+	r := make(map[string]AttributeInfo, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
+}
+
+// clone copies the item or room with the given ID.
+// It must only be called from WorldThread.
+func (w *World) clone(e CloneMessage) Object {
+	if !w.withinQuota(e.Owner, e.Quota, w.cloneCost(e)) {
+		return nil
+	}
+	if i, ok := w.db.Items[e.ID]; ok {
+		loc := Location{ID: e.Owner, Type: LocationPlayer}
+		return w.cloneItem(i, e.Owner, loc, e.Deep, make(map[IDType]bool))
+	}
+	if r, ok := w.db.Rooms[e.ID]; ok {
+		return w.cloneRoom(r, e.Owner, e.Deep)
+	}
+	return nil
+}
+
+// cloneCost counts the rooms, items, and exits that cloning would create.
+func (w *World) cloneCost(e CloneMessage) map[ObjectType]int {
+	r := make(map[ObjectType]int)
+	seen := make(map[IDType]bool)
+	var countItem func(i *Item)
+	countItem = func(i *Item) {
+		seen[i.ID] = true
+		r[ObjectItem]++
+		if e.Deep {
+			for _, child := range w.findItemByLocation(Location{ID: i.ID, Type: LocationItem}) {
+				if !seen[child.ID] {
+					countItem(child)
+				}
+			}
+		}
+	}
+	if i, ok := w.db.Items[e.ID]; ok {
+		countItem(i)
+	} else if room, ok := w.db.Rooms[e.ID]; ok {
+		r[ObjectRoom]++
+		for _, x := range room.Exits {
+			if x != nil {
+				r[ObjectExit]++
+			}
+		}
+		if e.Deep {
+			for _, i := range w.findItemByLocation(Location{ID: room.ID, Type: LocationRoom}) {
+				countItem(i)
+			}
+		}
+	}
+	return r
+}
+
+// cloneItem copies an item into the given location.
+// If deep is set, the items inside it are copied into the new item.
+func (w *World) cloneItem(i *Item, owner IDType, loc Location, deep bool, seen map[IDType]bool) *Item {
+	seen[i.ID] = true
+	n := *i
+	n.ID = w.nextID()
+	n.Owner = owner
+	n.Editors = nil
+	n.History = nil
+	n.Location = loc
+	n.Attributes = copyAttributes(i.Attributes)
+	n.AttributeInfo = copyAttributeInfo(i.AttributeInfo)
+	w.db.Items[n.ID] = &n
+	log.Printf("Clone Item: %s -> %s\n", i.ID, n.ID)
+	if deep {
+		inside := Location{ID: n.ID, Type: LocationItem}
+		for _, child := range w.findItemByLocation(Location{ID: i.ID, Type: LocationItem}) {
+			if !seen[child.ID] {
+				w.cloneItem(child, owner, inside, deep, seen)
+			}
+		}
+	}
+	return &n
+}
+
+// cloneRoom copies a room along with its exits.
+// Exits that lead back into the original room lead into the new room instead.
+// If deep is set, the items in the room are copied into the new room.
+func (w *World) cloneRoom(r *Room, owner IDType, deep bool) *Room {
+	n := *r
+	n.ID = w.nextID()
+	n.Owner = owner
+	n.Editors = nil
+	n.History = nil
+	n.Attributes = copyAttributes(r.Attributes)
+	n.AttributeInfo = copyAttributeInfo(r.AttributeInfo)
+	n.Exits = make([]*Exit, 0, len(r.Exits))
+	for _, e := range r.Exits {
+		if e == nil {
+			continue
+		}
+		x := *e
+		x.ID = w.nextID()
+		x.Owner = owner
+		x.Editors = nil
+		x.History = nil
+		x.Room = n.ID
+		x.Aliases = append([]string(nil), e.Aliases...)
+		x.Attributes = copyAttributes(e.Attributes)
+		x.AttributeInfo = copyAttributeInfo(e.AttributeInfo)
+		if x.Destination == r.ID {
+			x.Destination = n.ID
+		}
+		n.Exits = append(n.Exits, &x)
+	}
+	w.db.Rooms[n.ID] = &n
+	log.Printf("Clone Room: %s -> %s\n", r.ID, n.ID)
+	if deep {
+		seen := make(map[IDType]bool)
+		loc := Location{ID: n.ID, Type: LocationRoom}
+		for _, i := range w.findItemByLocation(Location{ID: r.ID, Type: LocationRoom}) {
+			w.cloneItem(i, owner, loc, deep, seen)
+		}
+	}
+	return &n
+}
+
+// Clone executes the "@clone" command and copies an item or a room.
+// Cloned items are placed in the player's inventory.
+func (c *Connection) Clone(target string, deep bool) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	if k := t.ObjectType(); k != ObjectItem && k != ObjectRoom {
+		c.Printf("You can only clone items and rooms.\n")
+		return
+	}
+	if !c.canEdit(t, "clone") {
+		c.Printf("You don't have permission to clone %s.\n", t)
+		return
+	}
+	ack := make(chan Object)
+	c.Server.World.Clone <- CloneMessage{ID: t.ObjectID(), Owner: c.Player.ID, Deep: deep, Quota: c.quotas(c.Player), Ack: ack}
+	n := <-ack
+	if n == nil {
+		c.Printf("Couldn't clone %s. It would put you over your quota.\n", t)
+		return
+	}
+	c.Audit("clone", n.ObjectID(), "", t.String(), n.String())
+	c.Printf("Cloned %s as %s.\n", t, n)
+}
+
+// parseCloneArgs splits the arguments to "@clone" into a target and the deep flag.
+func parseCloneArgs(args []string) (target string, deep bool) {
+	if len(args) > 1 && strings.EqualFold(args[len(args)-1], "deep") {
+		return strings.Join(args[:len(args)-1], " "), true
+	}
+	return strings.Join(args, " "), false
+}
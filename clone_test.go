@@ -0,0 +1,76 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+func TestCloneRoom(t *testing.T) {
+	w := NewWorld()
+	r := w.db.Rooms[w.db.DefaultRoom]
+	r.Attributes["COLOR"] = "red"
+	box := &Item{ID: w.nextID(), Name: "box", Location: Location{ID: r.ID, Type: LocationRoom}, Container: true}
+	w.db.Items[box.ID] = box
+	ball := &Item{ID: w.nextID(), Name: "ball", Location: Location{ID: box.ID, Type: LocationItem}}
+	w.db.Items[ball.ID] = ball
+
+	n, ok := w.clone(CloneMessage{ID: r.ID, Owner: 42, Deep: true}).(*Room)
+	if !ok || n == nil {
+		t.Fatalf("clone(%s) didn't return a room.", r.ID)
+	}
+	if n.ID == r.ID || n.Owner != 42 || w.db.Rooms[n.ID] != n {
+		t.Errorf("clone(%s) = %+v, which isn't a new room owned by the cloner.", r.ID, n)
+	}
+	n.Attributes["COLOR"] = "blue"
+	if r.Attributes["COLOR"] != "red" {
+		t.Errorf("The clone shares its attributes with the original.")
+	}
+	if len(n.Exits) != len(r.Exits) || n.Exits[0] == r.Exits[0] || n.Exits[0].ID == r.Exits[0].ID {
+		t.Errorf("The clone's exits weren't copied.")
+	}
+	boxes := w.findItemByLocation(Location{ID: n.ID, Type: LocationRoom})
+	if len(boxes) != 1 || boxes[0].ID == box.ID || boxes[0].Owner != 42 {
+		t.Fatalf("The items in the room weren't copied: %v", boxes)
+	}
+	balls := w.findItemByLocation(Location{ID: boxes[0].ID, Type: LocationItem})
+	if len(balls) != 1 || balls[0].ID == ball.ID {
+		t.Errorf("The items in the box weren't copied: %v", balls)
+	}
+}
+
+func TestCloneItemShallow(t *testing.T) {
+	w := NewWorld()
+	box := &Item{ID: w.nextID(), Name: "box", Container: true}
+	w.db.Items[box.ID] = box
+	ball := &Item{ID: w.nextID(), Name: "ball", Location: Location{ID: box.ID, Type: LocationItem}}
+	w.db.Items[ball.ID] = ball
+
+	n, ok := w.clone(CloneMessage{ID: box.ID, Owner: 42}).(*Item)
+	if !ok || n == nil {
+		t.Fatalf("clone(%s) didn't return an item.", box.ID)
+	}
+	if n.Location != (Location{ID: 42, Type: LocationPlayer}) {
+		t.Errorf("The clone is at %s, but we expected it to be carried by the cloner.", n.Location)
+	}
+	if len(w.findItemByLocation(Location{ID: n.ID, Type: LocationItem})) != 0 {
+		t.Errorf("A shallow clone copied the items inside the original.")
+	}
+}
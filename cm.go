@@ -63,6 +63,36 @@ func (m *ConnectionManager) Connections() []*Connection {
 	return c
 }
 
+// Sessions returns a slice of the authenticated connections for the given player.
+func (m *ConnectionManager) Sessions(playerID IDType) []*Connection {
+	m.connMutex.RLock()
+	defer m.connMutex.RUnlock()
+	c := make([]*Connection, 0)
+	for _, conn := range m.connections {
+		if conn.Authenticated && conn.Player != nil && conn.Player.ID == playerID {
+			c = append(c, conn)
+		}
+	}
+	return c
+}
+
+// PlayerConnections returns one authenticated connection for each player who is online.
+// Players with several sessions are only included once.
+func (m *ConnectionManager) PlayerConnections() []*Connection {
+	m.connMutex.RLock()
+	defer m.connMutex.RUnlock()
+	c := make([]*Connection, 0, len(m.connections))
+	seen := make(map[IDType]bool)
+	for _, conn := range m.connections {
+		if !conn.Authenticated || conn.Player == nil || seen[conn.Player.ID] {
+			continue
+		}
+		seen[conn.Player.ID] = true
+		c = append(c, conn)
+	}
+	return c
+}
+
 // findConnection iterates through the connections slice for a given connection id and returns the first matching connection.
 func (m *ConnectionManager) findConnection(id IDType) int {
 	i := -1
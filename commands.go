@@ -21,6 +21,7 @@ package mush
 
 import (
 	"fmt"
+	"math/rand"
 	"strconv"
 	"strings"
 	"time"
@@ -32,7 +33,29 @@ func addCommands(c *Connection) {
 	shell := c.Shell
 	player := c.Player
 
-	shell.AddCmd(&ishell.Cmd{
+	// addCmd adds a command that can only be used by the roles allowed by commandPolicy.
+	addCmd := func(cmd *ishell.Cmd) {
+		f := cmd.Func
+		name := cmd.Name
+		cmd.Func = func(e *ishell.Context) {
+			c.StopWalking()
+			if !c.CanUseCommand(name) {
+				c.updateIdleTime()
+				c.Printf("You don't have permission to use %s.\n", name)
+				return
+			}
+			f(e)
+		}
+		shell.AddCmd(cmd)
+	}
+
+	shell.NotFound(func(e *ishell.Context) {
+		c.StopWalking()
+		c.updateIdleTime()
+		c.notFound(e.Args)
+	})
+
+	addCmd(&ishell.Cmd{
 		Name: "exit",
 		Help: "Log off",
 		Func: func(e *ishell.Context) {
@@ -41,7 +64,7 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "say",
 		Help: "Say something to the everybody else. Usage: say [player] <message>",
 		Func: func(e *ishell.Context) {
@@ -66,7 +89,7 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "whisper",
 		Help: "Whisper something to the somebody else. Usage: whisper <player> <message>",
 		Func: func(e *ishell.Context) {
@@ -85,7 +108,7 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "emote",
 		Help: "Do something. Usage: emote <action>",
 		Func: func(e *ishell.Context) {
@@ -103,20 +126,24 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "look",
-		Help: "Look around. Usage: look [target]",
+		Help: "Look around. Usage: look [target] or look in <container>",
 		Func: func(e *ishell.Context) {
 			c.updateIdleTime()
 			target := ""
 			if len(e.Args) > 0 {
 				target = e.Args[0]
 			}
+			if len(e.Args) > 1 && strings.ToLower(e.Args[0]) == "in" {
+				c.LookIn(strings.Join(e.Args[1:], " "))
+				return
+			}
 			c.Look(target)
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "who",
 		Help: "See who's online",
 		Func: func(e *ishell.Context) {
@@ -125,42 +152,36 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "save",
-		Help: "Save world state (admin)",
+		Help: "Save world state (staff)",
 		Func: func(e *ishell.Context) {
 			c.updateIdleTime()
-			if c.IsAdmin() {
-				c.Printf("Saving world state...")
-				ack := make(chan error)
-				c.Server.World.SaveWorldState <- SaveWorldStateMessage{Ack: ack}
-				err := <-ack
-				if err != nil {
-					c.Printf("Error: %s\n", err.Error())
-				} else {
-					c.Printf("Complete\n")
-				}
+			c.Printf("Saving world state...")
+			c.Audit("save", 0, "", "", "")
+			ack := make(chan error)
+			c.Server.World.SaveWorldState <- SaveWorldStateMessage{Ack: ack}
+			err := <-ack
+			if err != nil {
+				c.Printf("Error: %s\n", err.Error())
 			} else {
-				c.Printf("Not Authorized\n")
+				c.Printf("Complete\n")
 			}
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "shutdown",
 		Help: "Shutdown server (admin)",
 		Func: func(e *ishell.Context) {
 			c.updateIdleTime()
-			if c.IsAdmin() {
-				c.Printf("Shutting down the server...\n")
-				c.Server.Shutdown <- true
-			} else {
-				c.Printf("Not Authorized\n")
-			}
+			c.Printf("Shutting down the server...\n")
+			c.Audit("shutdown", 0, "", "", "")
+			c.Server.Shutdown <- true
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "create",
 		Help: "Creates a new room or item. Usage: create <room|item|exit> <name> [description]",
 		Func: func(e *ishell.Context) {
@@ -203,9 +224,35 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
+		Name: "@dig",
+		Help: "Creates a new room with exits to and from it. Usage: @dig <room name>[=<exit>;<aliases>[,<return exit>;<aliases>]]",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.Dig(strings.Join(e.Args, " "))
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@clone",
+		Help: "Copies an item, or a room and its exits. Add 'deep' to copy the items inside as well. Usage: @clone <target> [deep]",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.Clone(parseCloneArgs(e.Args))
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
 		Name: "destroy",
-		Help: "Destroys a room or item. Usage: destroy <room|item> <id>",
+		Help: "Destroys a room, exit, item, or player. Destroyed things can be restored with @undestroy until they are purged. A destroyed player's things go to the heir if one is given. Usage: destroy <room|exit|item> <id> or destroy player <id> [heir]",
 		Func: func(e *ishell.Context) {
 			c.updateIdleTime()
 			if len(e.Args) > 1 {
@@ -217,6 +264,15 @@ func addCommands(c *Connection) {
 				}
 				switch t {
 				case "room":
+					room := c.FindRoomByID(id)
+					if !c.CanDestroyRoom(room) {
+						c.Println("Couldn't Destroy Room")
+						return
+					}
+					if n := c.roomContents(room); n > 0 && !c.confirm(fmt.Sprintf("That room has %d exits and things in it. Destroy it anyway?", n)) {
+						c.Println("Room Not Destroyed")
+						return
+					}
 					r := c.DestroyRoom(id)
 					if r == nil {
 						c.Println("Couldn't Destroy Room")
@@ -231,6 +287,21 @@ func addCommands(c *Connection) {
 						c.Printf("Exit Destroyed: %s\n", ex.String())
 					}
 
+				case "player":
+					var heir *Player
+					if len(e.Args) > 2 {
+						heir = c.lookupPlayer(strings.Join(e.Args[2:], " "))
+						if heir == nil {
+							c.Printf("%s is not a player.\n", strings.Join(e.Args[2:], " "))
+							return
+						}
+					}
+					p := c.DestroyPlayer(id, heir)
+					if p == nil {
+						c.Println("Couldn't Destroy Player")
+					} else {
+						c.Printf("Player Destroyed: %s\n", p.String())
+					}
 				case "item":
 					i := c.DestroyItem(id)
 					if i == nil {
@@ -247,7 +318,43 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
+		Name: "@dbck",
+		Help: "Checks the world for broken references. Add 'fix' to repair them. Usage: @dbck [fix]",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			fix := len(e.Args) > 0 && strings.EqualFold(e.Args[0], "fix")
+			if len(e.Args) > 0 && !fix {
+				c.Println(e.Cmd.HelpText())
+				return
+			}
+			c.CheckDatabase(fix)
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@trash",
+		Help: "Lists destroyed objects that can still be restored. Usage: @trash",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			c.ShowTrash()
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@undestroy",
+		Help: "Restores a destroyed room, exit, or item. Exits that led into a restored room are listed so that they can be restored too. Usage: @undestroy <id>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.Undestroy(e.Args[0])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
 		Name: "list",
 		Help: "List your rooms or items. Usage: list <rooms|items|players>",
 		Func: func(e *ishell.Context) {
@@ -296,7 +403,7 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "inventory",
 		Help: "List what you are carrying",
 		Func: func(e *ishell.Context) {
@@ -309,7 +416,7 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "take",
 		Help: "Pick up an item from the room you are in.  Usage: take <name or id>",
 		Func: func(e *ishell.Context) {
@@ -322,7 +429,37 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
+		Name: "get",
+		Help: "Take an item out of a container, or pick up an item. Usage: get <name or id> [from <container>]",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			args := strings.Join(e.Args, " ")
+			if i := strings.LastIndex(args, " from "); i > 0 {
+				c.Get(args[:i], args[i+6:])
+			} else if len(e.Args) > 0 {
+				c.Take(e.Args[0])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "put",
+		Help: "Put an item you are carrying into a container. Usage: put <name or id> in <container>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			args := strings.Join(e.Args, " ")
+			if i := strings.LastIndex(args, " in "); i > 0 {
+				c.Put(args[:i], args[i+4:])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
 		Name: "drop",
 		Help: "Drop an item are carrying. Usage: drop <name or id>",
 		Func: func(e *ishell.Context) {
@@ -335,7 +472,7 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "set",
 		Help: "Sets a value on a player, room, item, or exit. Usage: set <target> <field_name> <value>",
 		Func: func(e *ishell.Context) {
@@ -348,46 +485,446 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
+		Name: "@set",
+		Help: "Sets or clears a flag on a player, room, item, or exit. Usage: @set <target>=[!]<flag>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			parts := strings.SplitN(strings.Join(e.Args, " "), "=", 2)
+			if len(parts) == 2 {
+				c.SetFlag(parts[0], parts[1])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@role",
+		Help: "Sets a player's role. Roles: guest, player, builder, staff, wizard, god. Usage: @role <player>=<role>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			parts := strings.SplitN(strings.Join(e.Args, " "), "=", 2)
+			if len(parts) == 2 {
+				c.SetRole(parts[0], parts[1])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@grant",
+		Help: "Lets another player edit a room, item, or exit that you own. Usage: @grant <target>=<player>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			parts := strings.SplitN(strings.Join(e.Args, " "), "=", 2)
+			if len(parts) == 2 {
+				c.Grant(parts[0], parts[1], true)
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@revoke",
+		Help: "Stops another player from editing a room, item, or exit that you own. Usage: @revoke <target>=<player>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			parts := strings.SplitN(strings.Join(e.Args, " "), "=", 2)
+			if len(parts) == 2 {
+				c.Grant(parts[0], parts[1], false)
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@quota",
+		Help: "Shows or changes building quotas. Usage: @quota [<player>] or @quota <player> <rooms|items|exits>=<limit|default>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			switch len(e.Args) {
+			case 0:
+				c.ShowQuota("")
+			case 1:
+				c.ShowQuota(e.Args[0])
+			default:
+				parts := strings.SplitN(strings.Join(e.Args[1:], " "), "=", 2)
+				if len(parts) == 2 {
+					c.SetQuota(e.Args[0], parts[0], parts[1])
+				} else {
+					c.Println(e.Cmd.HelpText())
+				}
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@audit",
+		Help: "Shows the audit log of building and admin actions. Usage: @audit [<target>|<player>] [<since>]",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			c.ShowAudit(e.Args)
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@history",
+		Help: "Shows the changes made to a player, room, item, or exit. Usage: @history <target>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.ShowHistory(strings.Join(e.Args, " "))
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@revert",
+		Help: "Undoes a change shown by @history. Usage: @revert <target> <revision>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 1 {
+				c.Revert(strings.Join(e.Args[:len(e.Args)-1], " "), e.Args[len(e.Args)-1])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@get",
+		Help: "Shows the attributes on a target. Usage: @get <target>[/<attribute or pattern>]",
+		LongHelp: "Shows the attributes on a target. Usage: @get <target>[/<attribute or pattern>]\n" +
+			"Attributes are set with '&<attribute> <target>=<value>' and cleared with '&<attribute> <target>'.",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.GetAttributes(strings.Join(e.Args, " "))
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@wipe",
+		Help: "Removes attributes from a target. Usage: @wipe <target>[/<pattern>]",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.WipeAttributes(strings.Join(e.Args, " "))
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@aflag",
+		Help: "Sets or clears attribute flags. Usage: @aflag <target>/<attribute>=[!]<visible|locked|no-inherit|wizard> ...",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			parts := strings.SplitN(strings.Join(e.Args, " "), "=", 2)
+			if len(parts) == 2 {
+				c.SetAttributeFlags(parts[0], parts[1])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@atype",
+		Help: "Sets the type of an attribute. Usage: @atype <target>/<attribute>=<string|number|boolean|id>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			parts := strings.SplitN(strings.Join(e.Args, " "), "=", 2)
+			if len(parts) == 2 {
+				c.SetAttributeType(parts[0], parts[1])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@parent",
+		Help: "Sets or clears the parent of a room, item, or exit. Usage: @parent <target>=[<parent id>]",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			parts := strings.SplitN(strings.Join(e.Args, " "), "=", 2)
+			if len(parts) == 2 {
+				c.SetParent(parts[0], parts[1])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
 		Name: "show",
 		Help: "Shows details about a player, room, item, or exit. Usage: show <target>",
 		Func: func(e *ishell.Context) {
 			c.updateIdleTime()
 			if len(e.Args) > 0 {
-				c.Show(e.Args[0])
+				c.Show(e.Args[0])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "go",
+		Help: "Go somewhere.  Usage: go <direction>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.Go(e.Args[0])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "home",
+		Help: "Go back to your home. Usage: home",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			c.GoHome()
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@link",
+		Help: "Sets the home of a player or item, or the destination of an exit. Usage: @link <target>=<destination>",
+		LongHelp: "Sets the home of a player or item, or the destination of an exit. Usage: @link <target>=<destination>\n" +
+			"Use 'me' for yourself and 'here' for the room you're in, for example: @link me=here\n" +
+			"You can link to rooms you can edit, or to rooms with the LINK_OK flag.",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			parts := strings.SplitN(strings.Join(e.Args, " "), "=", 2)
+			if len(parts) < 2 {
+				c.Println(e.Cmd.HelpText())
+				return
+			}
+			c.Link(parts[0], parts[1])
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@tel",
+		Help: "Teleports yourself or something you can edit. Usage: @tel <target>=<destination>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			parts := strings.SplitN(strings.Join(e.Args, " "), "=", 2)
+			if len(parts) < 2 {
+				c.Println(e.Cmd.HelpText())
+				return
+			}
+			c.Teleport(parts[0], parts[1])
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "path",
+		Help: "Shows the shortest way to a room. Usage: path <room>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.ShowPath(strings.Join(e.Args, " "))
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "walk",
+		Help: "Walks to a room. Type anything to stop. Usage: walk <room>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.Walk(strings.Join(e.Args, " "))
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "map",
+		Help: "Shows a map of the rooms around you. Usage: map [radius]",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			c.ShowMap(strings.Join(e.Args, " "))
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@map",
+		Help: "Exports a zone, or the rooms that can be reached from here, as a Graphviz DOT graph. Usage: @map [zone]",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			c.ExportMap(strings.Join(e.Args, " "))
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "roominfo",
+		Help: "Shows a GMCP Room.Info message describing the room you're in. Usage: roominfo",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			c.ShowRoomInfo()
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@zone",
+		Help: "Manages zones, which group rooms together. Usage: @zone <list|show|create|set|builder|ambient|assign|chown|export> ...",
+		LongHelp: "Manages zones, which group rooms together.\n" +
+			"  @zone list\n" +
+			"  @zone show <zone>\n" +
+			"  @zone create <name>\n" +
+			"  @zone set <zone> <name|prefix|flags>=<value>\n" +
+			"  @zone builder <zone> <add|remove> <player>\n" +
+			"  @zone ambient <zone> <add <message>|clear>\n" +
+			"  @zone assign <room>=<zone|none>\n" +
+			"  @zone chown <zone>=<player>\n" +
+			"  @zone export <zone>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			c.ZoneCommand(e.Args)
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@lock",
+		Help: "Sets a lock on a room, item, or exit. Usage: @lock <target>=<expression>",
+		LongHelp: "Sets a lock on a room, item, or exit. Usage: @lock <target>=<expression>\n" +
+			"Expressions can use #<id>, flag:<name>, attr:<name>[=<value>], !, &, |, and parentheses.\n" +
+			"Example: @lock down=#123 | flag:builder & !attr:banned",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			parts := strings.SplitN(strings.Join(e.Args, " "), "=", 2)
+			if len(parts) == 2 {
+				c.SetLock(parts[0], parts[1])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "@unlock",
+		Help: "Removes the lock from a room, item, or exit. Usage: @unlock <target>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.ClearLock(strings.Join(e.Args, " "))
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "search",
+		Help: "Search the room for hidden exits.",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			c.Search()
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "enter",
+		Help: "Climb inside something. Usage: enter <name or id>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.Enter(e.Args[0])
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "leave",
+		Help: "Climb out of whatever you are inside of.",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			c.Leave()
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "lock",
+		Help: "Locks an exit using a key you are carrying. Usage: lock <exit>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.LockExit(e.Args[0], true)
+			} else {
+				c.Println(e.Cmd.HelpText())
+			}
+		},
+	})
+
+	addCmd(&ishell.Cmd{
+		Name: "unlock",
+		Help: "Unlocks an exit using a key you are carrying. Usage: unlock <exit>",
+		Func: func(e *ishell.Context) {
+			c.updateIdleTime()
+			if len(e.Args) > 0 {
+				c.LockExit(e.Args[0], false)
 			} else {
 				c.Println(e.Cmd.HelpText())
 			}
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
-		Name: "go",
-		Help: "Go somewhere.  Usage: go <direction>",
+	addCmd(&ishell.Cmd{
+		Name: "summon",
+		Help: "Summons a player or item. (admin) Usage: summon <id>",
 		Func: func(e *ishell.Context) {
 			c.updateIdleTime()
 			if len(e.Args) > 0 {
-				c.Go(e.Args[0])
+				c.Summon(e.Args[0])
 			} else {
 				c.Println(e.Cmd.HelpText())
 			}
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
-		Name: "summon",
-		Help: "Summons a player or item. (admin) Usage: summon <id>",
+	addCmd(&ishell.Cmd{
+		Name: "totp",
+		Help: "Manages two-factor authentication. Usage: totp <status|enroll|confirm <code>|disable <code>>",
 		Func: func(e *ishell.Context) {
 			c.updateIdleTime()
 			if len(e.Args) > 0 {
-				c.Summon(e.Args[0])
+				code := ""
+				if len(e.Args) > 1 {
+					code = e.Args[1]
+				}
+				if !c.TOTP(e.Args[0], code) {
+					c.Println(e.Cmd.HelpText())
+				}
 			} else {
 				c.Println(e.Cmd.HelpText())
 			}
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "test-scripting",
 		Help: "Tests that the scripting environment is working properly.",
 		Func: func(e *ishell.Context) {
@@ -401,12 +938,13 @@ func addCommands(c *Connection) {
 		},
 	})
 
-	shell.AddCmd(&ishell.Cmd{
+	addCmd(&ishell.Cmd{
 		Name: "exec",
 		Help: "Executes a script. Usage: exec <code block>",
 		Func: func(e *ishell.Context) {
 			c.updateIdleTime()
 			if len(e.Args) > 0 {
+				c.Audit("exec", 0, "", "", e.Args[0])
 				err := c.ExecuteScript(e.Args[0])
 				if err != nil {
 					c.Printf("Error Executing Script: %s\n", err.Error())
@@ -419,9 +957,9 @@ func addCommands(c *Connection) {
 
 }
 
-// IsAdmin returns true if the player is an admin.
+// IsAdmin returns true if the player is a wizard or a god.
 func (c *Connection) IsAdmin() bool {
-	return c != nil && c.Authenticated && c.Player != nil && c.Player.Admin
+	return c.Role() >= RoleWizard
 }
 
 func (c *Connection) updateIdleTime() {
@@ -437,7 +975,7 @@ func (c *Connection) findPlayerConnectionByName(target string) (targetID IDType,
 		if conn.Authenticated && conn.Player != nil {
 			n := strings.ToLower(conn.Player.Name)
 			if t == n {
-				targetID = conn.ID
+				targetID = conn.Player.ID
 				targetName = conn.Player.Name
 				loc = conn.Player.Location
 				break
@@ -464,13 +1002,13 @@ func (c *Connection) Say(target string, phrase string, loc *Location) {
 	}
 
 	// Send messages
-	for _, conn := range c.Server.Connections() {
+	for _, conn := range c.Server.PlayerConnections() {
 		switch {
 		case !conn.Authenticated:
 			// Do Nothing
-		case conn.ID == c.ID:
+		case conn.Player.ID == c.Player.ID:
 			// Do Nothing
-		case conn.ID == targetID && target != "":
+		case conn.Player.ID == targetID && target != "":
 			conn.Printf("%s says \"%s\" to you.\n", c.Player.Name, phrase)
 		case target == "" && conn.InLocation(loc):
 			conn.Printf("%s says \"%s\".\n", c.Player.Name, phrase)
@@ -500,13 +1038,13 @@ func (c *Connection) Whisper(target string, phrase string, loc *Location) {
 		return
 	}
 
-	for _, conn := range c.Server.Connections() {
+	for _, conn := range c.Server.PlayerConnections() {
 		switch {
 		case !conn.Authenticated:
 			// Do Nothing
-		case conn.ID == c.ID:
+		case conn.Player.ID == c.Player.ID:
 			// Do Nothing
-		case conn.ID == targetID:
+		case conn.Player.ID == targetID:
 			conn.Printf("%s whispers \"%s\".\n", c.Player.Name, phrase)
 		case conn.InLocation(loc):
 			conn.Printf("%s whispers to %s.\n", c.Player.Name, targetName)
@@ -538,6 +1076,13 @@ func (c *Connection) Look(target string) {
 			} else {
 				s = c.lookRoom(r)
 			}
+		case LocationItem:
+			i := c.FindItemByID(loc.ID)
+			if i == nil {
+				s = "You are lost.\n"
+			} else {
+				s = c.lookInterior(i)
+			}
 		default:
 			// Not Yet Supported
 			s = "You don't know where you are.\n"
@@ -546,30 +1091,23 @@ func (c *Connection) Look(target string) {
 	} else {
 		// Look at a given target
 		t := c.findTarget(target)
-		if t != nil {
+		if t != nil && c.passesLock(c.lockOf(t)) {
 			c.Printf("%s\n", c.lookThing(t))
 		}
 	}
 }
 
-func (c *Connection) lookThing(t interface{}) string {
+// lockOf returns the lock on the given thing.
+func (c *Connection) lockOf(t Object) Lock {
+	if l := t.lock(); l != nil {
+		return *l
+	}
+	return Lock{}
+}
+
+func (c *Connection) lookThing(t Object) string {
 	if c != nil && t != nil {
-		i, ok := t.(*Item)
-		if ok {
-			return c.lookItem(i)
-		}
-		p, ok := t.(*Player)
-		if ok {
-			return c.lookPlayer(p)
-		}
-		e, ok := t.(*Exit)
-		if ok {
-			return c.lookExit(e)
-		}
-		r, ok := t.(*Room)
-		if ok {
-			return c.lookRoom(r)
-		}
+		return t.look(c)
 	}
 	return ""
 }
@@ -582,23 +1120,43 @@ func (c *Connection) lookRoom(r *Room) string {
 
 	loc := Location{ID: r.ID, Type: LocationRoom}
 
+	d, _ := c.inheritedDescription(r)
 	s := r.String() + "\n"
-	s += r.Description + "\n"
+	if z := c.zoneOf(r); z != nil {
+		s = fmt.Sprintf("%s (%s)\n", r, z.Name)
+		if z.DescriptionPrefix != "" {
+			d = z.DescriptionPrefix + " " + d
+		}
+	}
+	s += d + "\n"
 	// Exits
 	for _, exit := range r.Exits {
-		s += fmt.Sprintf("%s [%s]\n", exit.Description, exit.Name)
+		if c.CanSeeExit(exit) && c.canSeeDark(exit) {
+			d, _ := c.inheritedDescription(exit)
+			s += fmt.Sprintf("%s [%s]\n", d, exit.Name)
+			if exit.Flags.Has(FlagTransparent) {
+				if dest := c.FindRoomByID(exit.Destination); dest != nil {
+					s += fmt.Sprintf("Through the %s you can see %s.\n", exit.Name, dest.Name)
+				}
+			}
+		}
+	}
+
+	if c.roomFlags(r).Has(FlagDark) && !c.IsAdmin() {
+		s += "It is too dark to see anything else.\n\n"
+		return s
 	}
 
 	// Items
 	for _, item := range c.FindItemsByLocation(loc) {
-		if item != nil {
+		if item != nil && c.canSeeDark(item) {
 			s += fmt.Sprintf("You see %s here.\n", item.Name)
 		}
 	}
 
 	// Players
 	for _, player := range c.FindOnlinePlayersByLocation(&loc) {
-		if player != nil && p.ID != player.ID {
+		if player != nil && p.ID != player.ID && c.canSeeDark(player) {
 			s += fmt.Sprintf("You see %s here.\n", player.Name)
 		}
 	}
@@ -607,11 +1165,17 @@ func (c *Connection) lookRoom(r *Room) string {
 	return s
 }
 
+// canSeeDark returns true unless the thing is dark and the player isn't an admin.
+func (c *Connection) canSeeDark(t Object) bool {
+	return !t.ObjectFlags().Has(FlagDark) || c.IsAdmin()
+}
+
 func (c *Connection) lookItem(i *Item) string {
 	if c == nil || c.Player == nil || !c.Authenticated || i == nil {
 		return ""
 	}
-	return fmt.Sprintf("%s\n", i.Description)
+	d, _ := c.inheritedDescription(i)
+	return fmt.Sprintf("%s\n", d)
 }
 
 func (c *Connection) lookPlayer(p *Player) string {
@@ -625,7 +1189,22 @@ func (c *Connection) lookExit(e *Exit) string {
 	if c == nil || c.Player == nil || !c.Authenticated || e == nil {
 		return ""
 	}
-	return fmt.Sprintf("%s\n", e.LongDescription)
+	d, _ := c.inheritedLongDescription(e)
+	s := fmt.Sprintf("%s\n", d)
+	if e.Flags.Has(FlagTransparent) {
+		if dest := c.FindRoomByID(e.Destination); dest != nil {
+			d, _ := c.inheritedDescription(dest)
+			s += fmt.Sprintf("Through it you can see %s.\n%s\n", dest.Name, d)
+		}
+	}
+	if e.Lockable {
+		if e.Locked {
+			s += "It is closed and locked.\n"
+		} else {
+			s += "It is open.\n"
+		}
+	}
+	return s
 }
 
 const (
@@ -650,7 +1229,7 @@ func (c *Connection) Who() {
 		if conn.Authenticated && conn.Player != nil {
 			playerName = conn.Player.String()
 			locName = c.LocationName(conn.Player.Location)
-			if conn.Player.Admin {
+			if conn.Player.EffectiveRole() >= RoleWizard {
 				admin = "Yes"
 			}
 		}
@@ -680,7 +1259,7 @@ func (c *Connection) ListPlayers(players []*Player) {
 		if p != nil {
 			playerName = p.String()
 			locName = c.LocationName(p.Location)
-			if p.Admin {
+			if p.EffectiveRole() >= RoleWizard {
 				admin = "Yes"
 			}
 			active = fmt.Sprintf("%s ago", time.Since(p.LastActed).String())
@@ -727,11 +1306,13 @@ func (c *Connection) Take(itemName string) {
 	} else if foundOne != nil {
 		// Single item found
 		item, ok := foundOne.(*Item)
-		if ok && (!item.Attached || item.Owner == c.Player.ID || c.IsAdmin()) {
+		if !ok || (item.Flags.Has(FlagAttached) && item.Owner != c.Player.ID && !c.IsAdmin()) {
+			c.Printf("You can't take that.\n")
+		} else if len(c.FindPlayersByLocation(Location{ID: item.ID, Type: LocationItem})) > 0 {
+			c.Printf("You can't take that while somebody is inside it.\n")
+		} else if c.passesLock(item.Lock) {
 			item.Location = Location{ID: c.Player.ID, Type: LocationPlayer}
 			c.Emote(fmt.Sprintf("picks up %s", item.Name), &c.Player.Location)
-		} else {
-			c.Printf("You can't take that.\n")
 		}
 	} else {
 		// No items found
@@ -757,6 +1338,9 @@ func (c *Connection) Drop(itemName string) {
 		if ok {
 			item.Location = c.Player.Location
 			c.Emote(fmt.Sprintf("drops %s", item.Name), &c.Player.Location)
+			if item.Flags.Has(FlagSticky) {
+				c.sendHome(item)
+			}
 		} else {
 			c.Printf("You can't drop that.\n")
 		}
@@ -771,7 +1355,6 @@ func (c *Connection) Go(target string) {
 	if c == nil || !c.Authenticated || c.Player == nil {
 		return
 	}
-	t := strings.TrimSpace(strings.ToLower(target))
 	switch c.Player.Location.Type {
 	case LocationRoom:
 		r := c.FindRoomByID(c.Player.Location.ID)
@@ -779,23 +1362,254 @@ func (c *Connection) Go(target string) {
 			c.Printf("You're Lost!\n")
 			return
 		}
-		for _, e := range r.Exits {
-			if strings.ToLower(e.Name) == t {
-				dest := c.FindRoomByID(e.Destination)
-				if dest == nil {
-					c.Printf("That doesn't seem to go anywhere.\n")
-					return
-				}
-				// TODO: Handle locks here!
-				c.Move(Location{ID: dest.ID, Type: LocationRoom}, e.LeaveMessage, e.ArriveMessage)
-			}
+		e := c.findExit(r, target)
+		if e == nil {
+			c.Printf("You can't go that way.\n")
+			return
 		}
+		c.useExit(e)
+	case LocationItem:
+		i := c.FindItemByID(c.Player.Location.ID)
+		if i == nil || !i.Vehicle {
+			c.Printf("You need to leave first.\n")
+			return
+		}
+		c.Drive(i, target)
 	default:
 		c.Printf("You're not in a room!\n")
 		return
 	}
 }
 
+// useExit moves the player through an exit in their room.
+// It returns false if the exit doesn't go anywhere or the player can't get through it.
+func (c *Connection) useExit(e *Exit) bool {
+	dest := c.FindRoomByID(e.Destination)
+	if dest == nil {
+		c.Printf("That doesn't seem to go anywhere.\n")
+		return false
+	}
+	if e.Locked {
+		c.Printf("The %s is locked.\n", e.Name)
+		return false
+	}
+	if !c.passesLock(e.Lock) || !c.passesLock(dest.Lock) {
+		return false
+	}
+	c.Move(Location{ID: dest.ID, Type: LocationRoom}, e.LeaveMessage, e.ArriveMessage)
+	return true
+}
+
+// findExit returns the exit in the given room that matches the given name.
+func (c *Connection) findExit(r *Room, name string) *Exit {
+	if r == nil {
+		return nil
+	}
+	for _, e := range r.Exits {
+		if e.Matches(name) && c.CanSeeExit(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+// exitRoom returns the room whose exits the player can use.
+// This is the player's room, or the room that their vehicle is in.
+func (c *Connection) exitRoom() *Room {
+	loc := c.Player.Location
+	if loc.Type == LocationItem {
+		i := c.FindItemByID(loc.ID)
+		if i == nil || !i.Vehicle {
+			return nil
+		}
+		loc = i.Location
+	}
+	if loc.Type != LocationRoom {
+		return nil
+	}
+	return c.FindRoomByID(loc.ID)
+}
+
+// notFound handles input that doesn't match any command.
+// If the input is the name of an exit, the player goes that way.
+func (c *Connection) notFound(args []string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	if len(args) == 0 {
+		return
+	}
+	if strings.HasPrefix(args[0], "&") && len(args[0]) > 1 {
+		// &<attribute> <target>=<value>
+		parts := strings.SplitN(strings.Join(args[1:], " "), "=", 2)
+		value := ""
+		if len(parts) > 1 {
+			value = strings.TrimSpace(parts[1])
+		}
+		c.SetAttribute(args[0][1:], parts[0], value)
+		return
+	}
+	target := strings.Join(args, " ")
+	if c.findExit(c.exitRoom(), target) != nil {
+		c.Go(target)
+		return
+	}
+	c.Printf("Huh? Type 'help' for a list of commands.\n")
+}
+
+// CanSeeExit returns true if the exit isn't hidden from the player.
+// Players can see hidden exits that they have found or that they are allowed to edit.
+func (c *Connection) CanSeeExit(e *Exit) bool {
+	if c == nil || !c.Authenticated || c.Player == nil || e == nil {
+		return false
+	}
+	return !e.Flags.Has(FlagHidden) || c.Player.Discovered[e.ID] || c.CanEditExit(e, "hidden")
+}
+
+// Search executes the "search" command, which looks for hidden exits in the player's room.
+// Exits that are found are remembered, so the player will keep seeing them.
+func (c *Connection) Search() {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	if c.Player.Location.Type != LocationRoom {
+		c.Printf("You don't find anything.\n")
+		return
+	}
+	r := c.FindRoomByID(c.Player.Location.ID)
+	if r == nil {
+		c.Printf("You're Lost!\n")
+		return
+	}
+	c.Emote("searches the area", &c.Player.Location)
+	found := false
+	for _, e := range r.Exits {
+		if !e.Flags.Has(FlagHidden) || c.Player.Discovered[e.ID] {
+			continue
+		}
+		if e.SearchLock != "" {
+			if !c.evalLock(e.SearchLock) {
+				continue
+			}
+		} else {
+			chance := e.SearchChance
+			if chance == 0 {
+				chance = c.Server.Config.SearchChance
+			}
+			if rand.Intn(100) >= chance {
+				continue
+			}
+		}
+		if c.Player.Discovered == nil {
+			c.Player.Discovered = make(map[IDType]bool)
+		}
+		c.Player.Discovered[e.ID] = true
+		c.Printf("You found a hidden exit! %s [%s]\n", e.Description, e.Name)
+		found = true
+	}
+	if !found {
+		c.Printf("You don't find anything.\n")
+	}
+}
+
+// LockExit executes the "lock" and "unlock" commands, which lock or unlock an exit in the player's room.
+// The player must be carrying the exit's key.
+// The matching exit on the other side is locked or unlocked as well.
+func (c *Connection) LockExit(target string, locked bool) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	if c.Player.Location.Type != LocationRoom {
+		c.Printf("You're not in a room!\n")
+		return
+	}
+	r := c.FindRoomByID(c.Player.Location.ID)
+	if r == nil {
+		c.Printf("You're Lost!\n")
+		return
+	}
+	e := c.findExit(r, target)
+	action := "lock"
+	if !locked {
+		action = "unlock"
+	}
+	switch {
+	case e == nil:
+		c.Printf("There's no %s here.\n", target)
+	case !e.Lockable:
+		c.Printf("You can't %s the %s.\n", action, e.Name)
+	case e.Locked == locked:
+		c.Printf("The %s is already %sed.\n", e.Name, action)
+	case e.Key == 0 || !playerLockContext{c: c, p: c.Player}.HasObject(e.Key):
+		c.Printf("You don't have the key.\n")
+	default:
+		c.setExitLocked(r, e, locked)
+		c.Printf("You %s the %s.\n", action, e.Name)
+		c.Emote(fmt.Sprintf("%ss the %s", action, e.Name), &c.Player.Location)
+	}
+}
+
+// setExitLocked locks or unlocks an exit along with the matching exit in its destination room.
+func (c *Connection) setExitLocked(r *Room, e *Exit, locked bool) {
+	e.Locked = locked
+	state := "locked"
+	if !locked {
+		state = "unlocked"
+	}
+	other, ambiguous := c.counterpartExit(r, e)
+	if ambiguous {
+		c.Printf("More than one exit leads back through the %s, so the other side wasn't %s.\n", e.Name, state)
+	}
+	if other != nil && other.Lockable {
+		other.Locked = locked
+		c.LocationPrintf(&Location{ID: e.Destination, Type: LocationRoom}, "You hear the %s being %s from the other side.\n", other.Name, state)
+	}
+}
+
+// counterpartExit returns the exit in the destination room that leads back to the room the given exit is in.
+// If more than one exit leads back, the one with the same key or else the same name is used.
+// If that still doesn't pick out a single exit, it returns nil and true.
+func (c *Connection) counterpartExit(r *Room, e *Exit) (*Exit, bool) {
+	if r == nil || e == nil {
+		return nil, false
+	}
+	dest := c.FindRoomByID(e.Destination)
+	if dest == nil {
+		return nil, false
+	}
+	var back []*Exit
+	for _, ex := range dest.Exits {
+		if ex.Destination == r.ID {
+			back = append(back, ex)
+		}
+	}
+	for _, same := range []func(*Exit) bool{
+		func(ex *Exit) bool { return e.Key != 0 && ex.Key == e.Key },
+		func(ex *Exit) bool { return strings.EqualFold(ex.Name, e.Name) },
+	} {
+		if len(back) < 2 {
+			break
+		}
+		var matched []*Exit
+		for _, ex := range back {
+			if same(ex) {
+				matched = append(matched, ex)
+			}
+		}
+		if len(matched) > 0 {
+			back = matched
+		}
+	}
+	switch len(back) {
+	case 0:
+		return nil, false
+	case 1:
+		return back[0], false
+	default:
+		return nil, true
+	}
+}
+
 // Move transports a player to another location.
 // leaveMessage should contain "%s" for the player's name.
 // arriveMessage should contain "%s" for the player's name.
@@ -809,7 +1623,7 @@ func (c *Connection) Move(destination Location, leaveMessage string, arriveMessa
 	c.LocationPrintf(&destination, arriveMessage+"\n", c.Player.Name)
 }
 
-func (c *Connection) findTarget(target string) fmt.Stringer {
+func (c *Connection) findTarget(target string) Object {
 	if c == nil || !c.Authenticated || c.Player == nil {
 		return nil
 	}
@@ -817,7 +1631,7 @@ func (c *Connection) findTarget(target string) fmt.Stringer {
 	roomTarget, foundRoomTargets := c.FindLocalThing(c.Player.Location, target, true)
 	playerTarget, foundPlayerTargets := c.FindLocalThing(Location{ID: c.Player.ID, Type: LocationPlayer}, target, true)
 
-	targets := make(map[string]fmt.Stringer)
+	targets := make(map[string]Object)
 	if roomTarget != nil {
 		targets[roomTarget.String()] = roomTarget
 	}
@@ -870,23 +1684,18 @@ func (c *Connection) Set(target string, field string, value string) {
 
 }
 
-func (c *Connection) setThing(t interface{}, field string, value string) {
+// canEdit returns true if the player can edit the field on the given thing.
+func (c *Connection) canEdit(t Object, field string) bool {
+	return t != nil && t.canEdit(c, field)
+}
+
+// setThing sets a field on a thing and records the change in the audit log and the thing's history.
+func (c *Connection) setThing(t Object, field string, value string) {
 	if c != nil && t != nil {
-		i, ok := t.(*Item)
-		if ok {
-			c.setItem(i, field, value)
-		}
-		p, ok := t.(*Player)
-		if ok {
-			c.setPlayer(p, field, value)
-		}
-		e, ok := t.(*Exit)
-		if ok {
-			c.setExit(e, field, value)
-		}
-		r, ok := t.(*Room)
-		if ok {
-			c.setRoom(r, field, value)
+		old := fieldValue(t, field)
+		t.set(c, field, value)
+		if v := fieldValue(t, field); v != old {
+			c.changed(t, "set", historyField(field), old, v)
 		}
 	}
 }
@@ -915,7 +1724,7 @@ func (c *Connection) setItem(i *Item, field string, value string) {
 		}
 		p := c.FindPlayerByID(id)
 		if p == nil {
-			c.Printf("%s is not a player.\n")
+			c.Printf("%s is not a player.\n", id)
 			return
 		}
 		i.Owner = id
@@ -925,13 +1734,60 @@ func (c *Connection) setItem(i *Item, field string, value string) {
 			c.Printf("Attached can only be set to either 'true' or 'false'.\n")
 			return
 		}
-		i.Attached = b
+		i.Flags = setFlag(i.Flags, FlagAttached, b)
+	case "container":
+		b, err := strconv.ParseBool(strings.TrimSpace(strings.ToLower(value)))
+		if err != nil {
+			c.Printf("Container can only be set to either 'true' or 'false'.\n")
+			return
+		}
+		i.Container = b
+	case "capacity":
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			c.Printf("Capacity must be a number that is zero or more.\n")
+			return
+		}
+		i.Capacity = n
+	case "enterable":
+		b, err := strconv.ParseBool(strings.TrimSpace(strings.ToLower(value)))
+		if err != nil {
+			c.Printf("Enterable can only be set to either 'true' or 'false'.\n")
+			return
+		}
+		i.Enterable = b
+	case "vehicle":
+		b, err := strconv.ParseBool(strings.TrimSpace(strings.ToLower(value)))
+		if err != nil {
+			c.Printf("Vehicle can only be set to either 'true' or 'false'.\n")
+			return
+		}
+		i.Vehicle = b
+	case "interior":
+		i.Interior = value
+	case "home":
+		id, ok := c.setHome(i, value)
+		if !ok {
+			return
+		}
+		i.Home = id
+	case "fail":
+		fallthrough
+	case "failmessage":
+		i.Lock.FailMessage = value
+	case "roomfail":
+		fallthrough
+	case "roomfailmessage":
+		i.Lock.RoomFailMessage = value
 	default:
 		c.Printf("Can't set %s on %s.\n", field, i)
 		supportedFields := []string{
 			"name", "(desc)ription", "owner", "attached",
+			"container", "capacity", "enterable", "vehicle", "interior", "home",
+			"(fail)message", "(roomfail)message",
 		}
 		c.Printf("Fields: %s\n", strings.Join(supportedFields, ", "))
+		c.Printf("Use '&<attribute> <target>=<value>' to set an attribute.\n")
 		return
 	}
 	c.Printf("Set.\n")
@@ -951,12 +1807,20 @@ func (c *Connection) setPlayer(p *Player, field string, value string) {
 		fallthrough
 	case "description":
 		p.Description = value
+	case "home":
+		id, ok := c.setHome(p, value)
+		if !ok {
+			return
+		}
+		p.Home = id
 	default:
 		c.Printf("Can't set %s on %s.\n", field, p)
 		supportedFields := []string{
 			"(desc)ription",
+			"home",
 		}
 		c.Printf("Fields: %s\n", strings.Join(supportedFields, ", "))
+		c.Printf("Use '&<attribute> <target>=<value>' to set an attribute.\n")
 		return
 	}
 	c.Printf("Set.\n")
@@ -974,6 +1838,8 @@ func (c *Connection) setExit(e *Exit, field string, value string) {
 	switch f {
 	case "name":
 		e.Name = value
+	case "aliases":
+		e.Aliases = parseAliases(value)
 	case "desc":
 		fallthrough
 	case "description":
@@ -998,14 +1864,17 @@ func (c *Connection) setExit(e *Exit, field string, value string) {
 			c.Printf("Destination must be an ID value of the form '@0'.\n")
 			return
 		}
-		r := c.FindRoomByID(id)
-		if r == nil {
-			c.Printf("%s is not a room.\n")
-			return
-		}
-		if !c.CanEditRoom(r, "exits") {
-			c.Printf("You don't have permission to link an exit to that room.\n")
-			return
+		if id != 0 {
+			// @0 unlinks the exit.
+			r := c.FindRoomByID(id)
+			if r == nil {
+				c.Printf("%s is not a room.\n", id)
+				return
+			}
+			if !c.CanEditRoom(r, "link") {
+				c.Printf("You don't have permission to link an exit to that room.\n")
+				return
+			}
 		}
 		e.Destination = id
 	case "owner":
@@ -1016,18 +1885,89 @@ func (c *Connection) setExit(e *Exit, field string, value string) {
 		}
 		p := c.FindPlayerByID(id)
 		if p == nil {
-			c.Printf("%s is not a player.\n")
+			c.Printf("%s is not a player.\n", id)
 			return
 		}
 		e.Owner = id
+	case "hidden":
+		b, err := strconv.ParseBool(strings.TrimSpace(strings.ToLower(value)))
+		if err != nil {
+			c.Printf("Hidden can only be set to either 'true' or 'false'.\n")
+			return
+		}
+		e.Flags = setFlag(e.Flags, FlagHidden, b)
+	case "searchchance":
+		// 0 means that the server's default is used, so it can only be set with "default".
+		n := 0
+		if v := strings.TrimSpace(value); !strings.EqualFold(v, "default") {
+			var err error
+			n, err = strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				c.Printf("SearchChance must be a number from 1 to 100, or 'default' to use the server's default.\n")
+				return
+			}
+		}
+		e.SearchChance = n
+	case "searchlock":
+		if strings.TrimSpace(value) != "" {
+			_, err := ParseLock(value)
+			if err != nil {
+				c.Printf("Error: %s\n", err.Error())
+				return
+			}
+		}
+		e.SearchLock = strings.TrimSpace(value)
+	case "lockable":
+		b, err := strconv.ParseBool(strings.TrimSpace(strings.ToLower(value)))
+		if err != nil {
+			c.Printf("Lockable can only be set to either 'true' or 'false'.\n")
+			return
+		}
+		e.Lockable = b
+		if !b {
+			e.Locked = false
+		}
+	case "locked":
+		b, err := strconv.ParseBool(strings.TrimSpace(strings.ToLower(value)))
+		if err != nil {
+			c.Printf("Locked can only be set to either 'true' or 'false'.\n")
+			return
+		}
+		if !e.Lockable {
+			c.Printf("%s is not lockable.\n", e)
+			return
+		}
+		c.setExitLocked(c.FindRoomByID(e.Room), e, b)
+	case "key":
+		id, err := ParseID(value)
+		if err != nil {
+			c.Printf("Key must be an ID value of the form '@0'.\n")
+			return
+		}
+		if id != 0 && c.FindItemByID(id) == nil {
+			c.Printf("%s is not an item.\n", id)
+			return
+		}
+		e.Key = id
+	case "fail":
+		fallthrough
+	case "failmessage":
+		e.Lock.FailMessage = value
+	case "roomfail":
+		fallthrough
+	case "roomfailmessage":
+		e.Lock.RoomFailMessage = value
 	default:
 		c.Printf("Can't set %s on %s.\n", field, e)
 		supportedFields := []string{
-			"name", "(desc)ription", "(long)description",
+			"name", "aliases", "(desc)ription", "(long)description",
 			"(arrive)message", "(leave)message", "(dest)ination",
-			"owner",
+			"owner", "hidden", "searchchance", "searchlock",
+			"lockable", "locked", "key",
+			"(fail)message", "(roomfail)message",
 		}
 		c.Printf("Fields: %s\n", strings.Join(supportedFields, ", "))
+		c.Printf("Use '&<attribute> <target>=<value>' to set an attribute.\n")
 		return
 	}
 	c.Printf("Set.\n")
@@ -1057,16 +1997,30 @@ func (c *Connection) setRoom(r *Room, field string, value string) {
 		}
 		p := c.FindPlayerByID(id)
 		if p == nil {
-			c.Printf("%s is not a player.\n")
+			c.Printf("%s is not a player.\n", id)
 			return
 		}
 		r.Owner = id
+	case "zone":
+		if !c.setZone(r, value) {
+			return
+		}
+	case "fail":
+		fallthrough
+	case "failmessage":
+		r.Lock.FailMessage = value
+	case "roomfail":
+		fallthrough
+	case "roomfailmessage":
+		r.Lock.RoomFailMessage = value
 	default:
 		c.Printf("Can't set %s on %s.\n", field, r)
 		supportedFields := []string{
-			"name", "(desc)ription", "owner",
+			"name", "(desc)ription", "owner", "zone",
+			"(fail)message", "(roomfail)message",
 		}
 		c.Printf("Fields: %s\n", strings.Join(supportedFields, ", "))
+		c.Printf("Use '&<attribute> <target>=<value>' to set an attribute.\n")
 		return
 	}
 	c.Printf("Set.\n")
@@ -1077,10 +2031,6 @@ func (c *Connection) Summon(target string) {
 	if c == nil || !c.Authenticated || c.Player == nil {
 		return
 	}
-	if !c.IsAdmin() {
-		c.Printf("Only admins can summon things.\n")
-	}
-
 	t := c.findTarget(target)
 	if t != nil {
 		i, ok := t.(*Item)
@@ -1090,26 +2040,30 @@ func (c *Connection) Summon(target string) {
 				c.LocationPrintf(&i.Location, "%s dissapears suddenly.\n", i.Name)
 			case LocationPlayer:
 				// See if player is online
-				for _, conn := range c.Server.Connections() {
+				for _, conn := range c.Server.PlayerConnections() {
 					if conn.Player != nil && conn.Authenticated && conn.Player.ID == i.Location.ID {
 						conn.Printf("%s disappears suddenly from your inventory.\n", i.Name)
 					}
 				}
 			}
+			old := i.Location
 			i.Location = Location{Type: LocationPlayer, ID: c.Player.ID}
+			c.Audit("summon", i.ID, "location", old.String(), i.Location.String())
 			c.Printf("Summoned %s.\n", i)
 			return
 		}
 		p, ok := t.(*Player)
 		if ok {
 			// See if player is online
-			for _, conn := range c.Server.Connections() {
+			for _, conn := range c.Server.PlayerConnections() {
 				if conn.Player != nil && conn.Authenticated && conn.Player.ID == p.ID {
+					c.Audit("summon", p.ID, "location", p.Location.String(), c.Player.Location.String())
 					conn.Move(c.Player.Location, "%s disappears suddenly.", "%s appears suddenly.")
 					return
 				}
 			}
 			// If not, then move the old fashioned way
+			c.Audit("summon", p.ID, "location", p.Location.String(), c.Player.Location.String())
 			p.Location = c.Player.Location
 			c.Printf("Summoned %s.\n", p)
 			return
@@ -1129,6 +2083,69 @@ func (c *Connection) Summon(target string) {
 	c.Printf("Couldn't summon %s\n", target)
 }
 
+// TOTP executes the "totp" command, which manages the player's two-factor authentication.
+// It returns false if the action wasn't recognized.
+func (c *Connection) TOTP(action string, code string) bool {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return true
+	}
+	enrolled := c.hasTOTP(c.Player.ID)
+	switch strings.TrimSpace(strings.ToLower(action)) {
+	case "status":
+		if enrolled {
+			c.Printf("Two-factor authentication is enabled.\n")
+		} else {
+			c.Printf("Two-factor authentication is not enabled.\n")
+		}
+	case "enroll":
+		if enrolled {
+			c.Printf("You are already enrolled. Disable two-factor authentication first to enroll again.\n")
+			return true
+		}
+		secret, err := NewTOTPSecret()
+		if err != nil {
+			c.Printf("Error: %s\n", err.Error())
+			return true
+		}
+		c.pendingTOTP = secret
+		c.Printf("Add this URI to your authenticator app:\n%s\n", TOTPURI(c.Server.Config.TOTPIssuer, c.Player.Name, secret))
+		c.Printf("Secret: %s\n", secret)
+		c.Printf("Then type 'totp confirm <code>' to finish enrolling.\n")
+	case "confirm":
+		if c.pendingTOTP == "" {
+			c.Printf("Type 'totp enroll' first.\n")
+			return true
+		}
+		if !CheckTOTP(c.pendingTOTP, code, time.Now()) {
+			c.Printf("That code is not valid.\n")
+			return true
+		}
+		c.setTOTP(c.Player.ID, c.pendingTOTP, code)
+		c.pendingTOTP = ""
+		c.Log("Enrolled in two-factor authentication")
+		c.Printf("Two-factor authentication enabled.\n")
+	case "disable":
+		if !enrolled {
+			c.Printf("Two-factor authentication is not enabled.\n")
+			return true
+		}
+		if c.IsAdmin() && c.Server.Config.RequireAdminTOTP {
+			c.Printf("Admins are required to use two-factor authentication.\n")
+			return true
+		}
+		if !c.checkTOTP(c.Player.ID, code) {
+			c.Printf("That code is not valid.\n")
+			return true
+		}
+		c.setTOTP(c.Player.ID, "", "")
+		c.Log("Disabled two-factor authentication")
+		c.Printf("Two-factor authentication disabled.\n")
+	default:
+		return false
+	}
+	return true
+}
+
 // Show executes the "show" action by showing a given target's field values
 func (c *Connection) Show(target string) {
 	if c == nil || !c.Authenticated || c.Player == nil {
@@ -1141,24 +2158,9 @@ func (c *Connection) Show(target string) {
 	}
 }
 
-func (c *Connection) showThing(t interface{}) string {
+func (c *Connection) showThing(t Object) string {
 	if c != nil && t != nil {
-		i, ok := t.(*Item)
-		if ok {
-			return c.showItem(i)
-		}
-		p, ok := t.(*Player)
-		if ok {
-			return c.showPlayer(p)
-		}
-		e, ok := t.(*Exit)
-		if ok {
-			return c.showExit(e)
-		}
-		r, ok := t.(*Room)
-		if ok {
-			return c.showRoom(r)
-		}
+		return t.show(c)
 	}
 	return ""
 }
@@ -1170,17 +2172,23 @@ func (c *Connection) showItem(i *Item) string {
 	s := ""
 	f := "%15s : %s\n"
 	q := "%15s : %q\n"
-	a := "                  " + q
 	s += fmt.Sprintf(f, "ID", i.ID)
 	s += fmt.Sprintf(q, "Name", i.Name)
-	s += fmt.Sprintf(q, "Description", i.Description)
+	d, from := c.inheritedDescription(i)
+	s += showInherited("Description", d, from, i)
+	s += fmt.Sprintf(f, "Parent", i.Parent)
 	s += fmt.Sprintf(f, "Owner", i.Owner)
+	s += fmt.Sprintf(f, "Editors", showIDs(i.Editors))
 	s += fmt.Sprintf(f, "Location", c.LocationName(i.Location))
-	s += fmt.Sprintf(f, "Attached", strconv.FormatBool(i.Attached))
-	s += fmt.Sprintf(f, "Attributes", "")
-	for k, v := range i.Attributes {
-		s += fmt.Sprintf(a, k, v)
-	}
+	s += fmt.Sprintf(f, "Home", c.LocationName(c.itemHome(i)))
+	s += fmt.Sprintf(f, "Flags", i.Flags)
+	s += fmt.Sprintf(f, "Container", strconv.FormatBool(i.Container))
+	s += fmt.Sprintf(f, "Capacity", strconv.Itoa(i.Capacity))
+	s += fmt.Sprintf(f, "Enterable", strconv.FormatBool(i.Enterable))
+	s += fmt.Sprintf(f, "Vehicle", strconv.FormatBool(i.Vehicle))
+	s += fmt.Sprintf(q, "Interior", i.Interior)
+	s += c.showLock(i.Lock)
+	s += c.showAttributes(i)
 	return s
 }
 
@@ -1191,18 +2199,15 @@ func (c *Connection) showPlayer(p *Player) string {
 	s := ""
 	f := "%15s : %s\n"
 	q := "%15s : %q\n"
-	// a := "    " + q
 	s += fmt.Sprintf(f, "ID", p.ID)
 	s += fmt.Sprintf(q, "Name", p.Name)
 	s += fmt.Sprintf(q, "Description", p.Description)
 	s += fmt.Sprintf(f, "Location", c.LocationName(p.Location))
+	s += fmt.Sprintf(f, "Home", c.LocationName(c.playerHome(p)))
+	s += fmt.Sprintf(f, "Role", p.EffectiveRole())
+	s += fmt.Sprintf(f, "Flags", p.Flags)
 	s += fmt.Sprintf(f, "LastActed", p.LastActed)
-	s += fmt.Sprintf(f, "Attributes", "")
-	/*
-		for k, v := range p.Attributes {
-			s += fmt.Sprintf(a, k, v)
-		}
-	*/
+	s += c.showAttributes(p)
 	return s
 }
 
@@ -1213,23 +2218,31 @@ func (c *Connection) showExit(e *Exit) string {
 	s := ""
 	f := "%15s : %s\n"
 	q := "%15s : %q\n"
-	a := "                  " + q
 	s += fmt.Sprintf(f, "ID", e.ID)
 	s += fmt.Sprintf(q, "Name", e.Name)
-	s += fmt.Sprintf(q, "Description", e.Description)
-	s += fmt.Sprintf(q, "LongDescription", e.LongDescription)
+	s += fmt.Sprintf(q, "Aliases", strings.Join(e.Aliases, ";"))
+	d, from := c.inheritedDescription(e)
+	s += showInherited("Description", d, from, e)
+	d, from = c.inheritedLongDescription(e)
+	s += showInherited("LongDescription", d, from, e)
+	s += fmt.Sprintf(f, "Parent", e.Parent)
 	s += fmt.Sprintf(f, "Destination", e.Destination)
 	s += fmt.Sprintf(q, "ArriveMessage", e.ArriveMessage)
 	s += fmt.Sprintf(q, "LeaveMessage", e.LeaveMessage)
 	s += fmt.Sprintf(f, "Owner", e.Owner)
-	s += fmt.Sprintf(f, "Hidden", strconv.FormatBool(e.Hidden))
+	s += fmt.Sprintf(f, "Editors", showIDs(e.Editors))
+	s += fmt.Sprintf(f, "Flags", e.Flags)
+	chance := "default"
+	if e.SearchChance != 0 {
+		chance = strconv.Itoa(e.SearchChance)
+	}
+	s += fmt.Sprintf(f, "SearchChance", chance)
+	s += fmt.Sprintf(q, "SearchLock", e.SearchLock)
 	s += fmt.Sprintf(f, "Lockable", strconv.FormatBool(e.Lockable))
 	s += fmt.Sprintf(f, "Locked", strconv.FormatBool(e.Locked))
 	s += fmt.Sprintf(f, "Key", e.Key)
-	s += fmt.Sprintf(f, "Attributes", "")
-	for k, v := range e.Attributes {
-		s += fmt.Sprintf(a, k, v)
-	}
+	s += c.showLock(e.Lock)
+	s += c.showAttributes(e)
 	return s
 }
 
@@ -1240,19 +2253,39 @@ func (c *Connection) showRoom(r *Room) string {
 	s := ""
 	f := "%15s : %s\n"
 	q := "%15s : %q\n"
-	a := "                  " + q
 	b := "                  %s\n"
 	s += fmt.Sprintf(f, "ID", r.ID)
 	s += fmt.Sprintf(q, "Name", r.Name)
-	s += fmt.Sprintf(q, "Description", r.Description)
+	d, from := c.inheritedDescription(r)
+	s += showInherited("Description", d, from, r)
+	s += fmt.Sprintf(f, "Parent", r.Parent)
 	s += fmt.Sprintf(f, "Owner", r.Owner)
+	s += fmt.Sprintf(f, "Editors", showIDs(r.Editors))
+	s += fmt.Sprintf(f, "Zone", c.zoneOf(r))
+	s += fmt.Sprintf(f, "Flags", r.Flags)
+	s += c.showLock(r.Lock)
 	s += fmt.Sprintf(f, "Exits", "")
 	for _, e := range r.Exits {
 		s += fmt.Sprintf(b, e)
 	}
-	s += fmt.Sprintf(f, "Attributes", "")
-	for k, v := range r.Attributes {
-		s += fmt.Sprintf(a, k, v)
+	s += c.showAttributes(r)
+	return s
+}
+
+// showIDs formats a list of IDs for the "show" command.
+func showIDs(ids []IDType) string {
+	s := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s = append(s, id.String())
 	}
+	return strings.Join(s, " ")
+}
+
+func (c *Connection) showLock(l Lock) string {
+	s := ""
+	q := "%15s : %q\n"
+	s += fmt.Sprintf(q, "Lock", l.Expression)
+	s += fmt.Sprintf(q, "FailMessage", l.FailMessage)
+	s += fmt.Sprintf(q, "RoomFailMessage", l.RoomFailMessage)
 	return s
 }
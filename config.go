@@ -0,0 +1,112 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+)
+
+// SessionMode controls what happens when a player logs in while they are already connected.
+type SessionMode string
+
+const (
+	// SessionTakeover means that a new login replaces the player's existing session.
+	SessionTakeover SessionMode = "takeover"
+	// SessionMulti means that a player may have several sessions open at once.
+	// Output is mirrored to every session.
+	SessionMulti SessionMode = "multi"
+)
+
+// Config holds the server's configuration settings.
+type Config struct {
+	// SessionMode controls what happens when a player logs in while they are already connected.
+	SessionMode SessionMode
+	// RequireAdminTOTP forces admins to enroll in two-factor authentication the next time they log in.
+	RequireAdminTOTP bool
+	// TOTPIssuer is the issuer name that authenticator apps display next to the player's name.
+	TOTPIssuer string
+	// SearchChance is the percent chance of finding a hidden exit that doesn't set its own chance.
+	SearchChance int
+	// RoomQuota, ItemQuota, and ExitQuota are the most rooms, items, and exits that a player can own.
+	// Negative values mean that there is no limit. Admins can change the limits for each player.
+	RoomQuota int
+	ItemQuota int
+	ExitQuota int
+	// AuditFile is where building and admin actions are logged. An empty name turns off the audit log.
+	AuditFile string
+	// AuditMaxSize is the size in bytes at which the audit log is rotated. Zero means that it is never rotated.
+	AuditMaxSize int64
+	// AuditKeep is the number of rotated audit logs to keep.
+	AuditKeep int
+	// HistorySize is the number of revisions kept for each object. A negative value keeps every revision.
+	HistorySize int
+	// TrashRetention is the number of hours that destroyed objects are kept before they are purged.
+	// A negative value keeps them until they are restored.
+	TrashRetention int
+	// FallbackRoom is where players and things are moved when the room they are in is destroyed.
+	// Zero means the world's default room.
+	FallbackRoom IDType
+	// WalkDelay is the number of milliseconds between each step that the "walk" command takes.
+	WalkDelay int
+	// AmbientFrequency is the number of seconds between the ambient messages of each zone. Zero turns them off.
+	AmbientFrequency int
+}
+
+// DefaultConfig returns a Config containing the default settings.
+func DefaultConfig() *Config {
+	return &Config{
+		SessionMode:      SessionTakeover,
+		TOTPIssuer:       VersionName,
+		SearchChance:     25,
+		RoomQuota:        20,
+		ItemQuota:        50,
+		ExitQuota:        50,
+		AuditFile:        "audit.log",
+		AuditMaxSize:     10 * 1024 * 1024,
+		AuditKeep:        5,
+		HistorySize:      20,
+		TrashRetention:   7 * 24,
+		WalkDelay:        1000,
+		AmbientFrequency: 300,
+	}
+}
+
+// LoadConfig loads the server's configuration from disk.
+// Settings that are missing from the file keep their default values.
+func LoadConfig() (*Config, error) {
+	fn := "config.json"
+	cfg := DefaultConfig()
+	file, err := os.Open(fn)
+	if err != nil {
+		log.Printf("WARNING: Configuration file does not exist, using defaults: %s\n", err.Error())
+		return cfg, nil
+	}
+	defer file.Close()
+	dec := json.NewDecoder(file)
+	err = dec.Decode(cfg)
+	if err != nil {
+		log.Printf("ERROR: Could not load configuration: %s\n", err.Error())
+		return nil, err
+	}
+	log.Printf("Configuration Loaded\n")
+	return cfg, nil
+}
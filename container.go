@@ -0,0 +1,165 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"strings"
+)
+
+// findContainer finds a container that the player is carrying or that is in the same room as the player.
+func (c *Connection) findContainer(target string) *Item {
+	t := c.findTarget(target)
+	if t == nil {
+		return nil
+	}
+	i, ok := t.(*Item)
+	if !ok || !i.Container {
+		c.Printf("%s is not a container.\n", t)
+		return nil
+	}
+	return i
+}
+
+// isInside returns true if the item is the container or if the container is inside the item,
+// either directly or through other containers.
+// Putting the item into the container would create a cycle if this returns true.
+func (c *Connection) isInside(container *Item, item *Item) bool {
+	if container.ID == item.ID {
+		return true
+	}
+	seen := make(map[IDType]bool)
+	loc := container.Location
+	for loc.Type == LocationItem && !seen[loc.ID] {
+		if loc.ID == item.ID {
+			return true
+		}
+		seen[loc.ID] = true
+		parent := c.FindItemByID(loc.ID)
+		if parent == nil {
+			break
+		}
+		loc = parent.Location
+	}
+	return false
+}
+
+// canPutIn returns true if the item fits into the container.
+// The item can't go inside itself or its own contents, and the container can't be full.
+func (c *Connection) canPutIn(container *Item, item *Item) bool {
+	if c.isInside(container, item) {
+		c.Printf("You can't put %s inside itself.\n", item.Name)
+		return false
+	}
+	loc := Location{ID: container.ID, Type: LocationItem}
+	if container.Capacity > 0 && len(c.FindItemsByLocation(loc)) >= container.Capacity {
+		c.Printf("%s is full.\n", container.Name)
+		return false
+	}
+	return true
+}
+
+// Put executes the "put" command and moves an item from the player's inventory into a container.
+func (c *Connection) Put(itemName string, containerName string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	foundOne, foundMany := c.FindLocalThing(Location{ID: c.Player.ID, Type: LocationPlayer}, itemName, false)
+	if len(foundMany) > 0 {
+		// Multiple items found
+		c.Printf("Which item did you mean?\n")
+		for _, t := range foundMany {
+			c.Printf("%s\n", t)
+		}
+		return
+	}
+	item, ok := foundOne.(*Item)
+	if !ok {
+		c.Printf("You don't have that item.\n")
+		return
+	}
+	container := c.findContainer(containerName)
+	if container == nil {
+		return
+	}
+	if !c.canPutIn(container, item) || !c.passesLock(container.Lock) {
+		return
+	}
+	item.Location = Location{ID: container.ID, Type: LocationItem}
+	c.Emote(fmt.Sprintf("puts %s in %s", item.Name, container.Name), &c.Player.Location)
+}
+
+// Get executes the "get" command and moves an item out of a container and into the player's inventory.
+func (c *Connection) Get(itemName string, containerName string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	container := c.findContainer(containerName)
+	if container == nil {
+		return
+	}
+	if !c.passesLock(container.Lock) {
+		return
+	}
+	loc := Location{ID: container.ID, Type: LocationItem}
+	n := strings.TrimSpace(strings.ToLower(itemName))
+	matches := make([]*Item, 0)
+	for _, i := range c.FindItemsByLocation(loc) {
+		if strings.Contains(strings.ToLower(i.String()), n) {
+			matches = append(matches, i)
+		}
+	}
+	switch {
+	case len(matches) > 1:
+		c.Printf("Which item did you mean?\n")
+		for _, i := range matches {
+			c.Printf("%s\n", i)
+		}
+	case len(matches) == 0:
+		c.Printf("That item is not in %s.\n", container.Name)
+	case matches[0].Flags.Has(FlagAttached) && matches[0].Owner != c.Player.ID && !c.IsAdmin():
+		c.Printf("You can't take that.\n")
+	case c.passesLock(matches[0].Lock):
+		item := matches[0]
+		item.Location = Location{ID: c.Player.ID, Type: LocationPlayer}
+		c.Emote(fmt.Sprintf("takes %s from %s", item.Name, container.Name), &c.Player.Location)
+	}
+}
+
+// LookIn executes the "look in" command and lists the contents of a container.
+func (c *Connection) LookIn(containerName string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	container := c.findContainer(containerName)
+	if container == nil || !c.passesLock(container.Lock) {
+		return
+	}
+	items := c.FindItemsByLocation(Location{ID: container.ID, Type: LocationItem})
+	if len(items) == 0 {
+		c.Printf("%s is empty.\n", container.Name)
+		return
+	}
+	s := fmt.Sprintf("%s contains:\n", container.Name)
+	for _, i := range items {
+		s += fmt.Sprintf("  %s\n", i.Name)
+	}
+	c.Printf("%s\n", s)
+}
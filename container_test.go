@@ -0,0 +1,68 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+func TestPut(t *testing.T) {
+	w := NewWorld()
+	room := Location{ID: w.db.DefaultRoom, Type: LocationRoom}
+	p := &Player{ID: w.nextID(), Role: RoleWizard, Location: room}
+	w.db.Players[p.ID] = p
+	box := &Item{ID: w.nextID(), Name: "box", Container: true, Capacity: 1, Location: Location{ID: p.ID, Type: LocationPlayer}}
+	bag := &Item{ID: w.nextID(), Name: "bag", Container: true, Location: Location{ID: box.ID, Type: LocationItem}}
+	pouch := &Item{ID: w.nextID(), Name: "pouch", Container: true, Location: Location{ID: bag.ID, Type: LocationItem}}
+	coin := &Item{ID: w.nextID(), Name: "coin", Location: Location{ID: p.ID, Type: LocationPlayer}}
+	for _, i := range []*Item{box, bag, pouch, coin} {
+		w.db.Items[i.ID] = i
+	}
+	c := testConnection(testServer(t, w), p)
+
+	for _, x := range []struct {
+		container *Item
+		item      *Item
+		inside    bool
+	}{
+		{box, box, true},
+		{bag, box, true},
+		{pouch, box, true},
+		{box, pouch, false},
+		{pouch, coin, false},
+	} {
+		if inside := c.isInside(x.container, x.item); inside != x.inside {
+			t.Errorf("isInside(%s, %s) = %v, but we expected %v.", x.container.Name, x.item.Name, inside, x.inside)
+		}
+	}
+
+	c.Put("box", pouch.ID.String())
+	if box.Location.Type != LocationPlayer {
+		t.Errorf("The box was put into the pouch inside of it.")
+	}
+	c.Put("coin", "box")
+	if coin.Location.Type != LocationPlayer {
+		t.Errorf("The coin was put into the box, which was already full.")
+	}
+	c.Put("coin", pouch.ID.String())
+	if coin.Location.ID != pouch.ID {
+		t.Errorf("The coin wasn't put into the pouch.")
+	}
+}
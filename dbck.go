@@ -0,0 +1,675 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Problem is an inconsistency found in the world database.
+type Problem struct {
+	ID          IDType
+	Description string
+	Fixed       bool
+}
+
+func (p Problem) String() string {
+	s := fmt.Sprintf("%s: %s", p.ID, p.Description)
+	if p.Fixed {
+		s += " (fixed)"
+	}
+	return s
+}
+
+// CheckMessage is sent to Check to look for problems in the world database.
+// If Fix is set, the problems that can be repaired are repaired.
+type CheckMessage struct {
+	Fix bool
+	Ack chan []Problem
+}
+
+// dbChecker holds the state of a database check.
+type dbChecker struct {
+	db       *WorldDatabase
+	fix      bool
+	problems []Problem
+}
+
+// Check looks for dangling references, duplicate IDs, cycles, and a bad NextID in the database.
+// If fix is set, it repairs the problems that it can. Lost players and items are sent to DefaultRoom,
+// references to things that no longer exist are cleared, and duplicate IDs are renumbered.
+// Things that refer to objects in the trash are reported but left alone, so they still work if the object is restored.
+func (db *WorldDatabase) Check(fix bool) []Problem {
+	k := &dbChecker{db: db, fix: fix, problems: make([]Problem, 0)}
+	k.checkMaps()
+	k.checkNextID()
+	k.checkIDs()
+	k.checkDefaultRoom()
+	k.checkExits()
+	k.checkLocations()
+	k.checkOwners()
+	k.checkHomes()
+	k.checkZones()
+	k.checkParents()
+	k.checkAuth()
+	return k.problems
+}
+
+// report records a problem. It returns true if the problem should be fixed.
+func (k *dbChecker) report(id IDType, fixable bool, format string, a ...interface{}) bool {
+	fixed := k.fix && fixable
+	k.problems = append(k.problems, Problem{ID: id, Description: fmt.Sprintf(format, a...), Fixed: fixed})
+	return fixed
+}
+
+func (k *dbChecker) nextID() IDType {
+	i := k.db.NextID
+	k.db.NextID++
+	return i
+}
+
+// sortedIDs returns the IDs passed to f by keys in order.
+func sortedIDs(n int, keys func(func(IDType))) []IDType {
+	r := make([]IDType, 0, n)
+	keys(func(id IDType) { r = append(r, id) })
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return r
+}
+
+// playerIDs, roomIDs, itemIDs, zoneIDs, and trashIDs return the IDs in each table in order, skipping empty entries.
+func (k *dbChecker) playerIDs() []IDType {
+	return sortedIDs(len(k.db.Players), func(f func(IDType)) {
+		for id, p := range k.db.Players {
+			if p != nil {
+				f(id)
+			}
+		}
+	})
+}
+
+func (k *dbChecker) roomIDs() []IDType {
+	return sortedIDs(len(k.db.Rooms), func(f func(IDType)) {
+		for id, r := range k.db.Rooms {
+			if r != nil {
+				f(id)
+			}
+		}
+	})
+}
+
+func (k *dbChecker) itemIDs() []IDType {
+	return sortedIDs(len(k.db.Items), func(f func(IDType)) {
+		for id, i := range k.db.Items {
+			if i != nil {
+				f(id)
+			}
+		}
+	})
+}
+
+func (k *dbChecker) zoneIDs() []IDType {
+	return sortedIDs(len(k.db.Zones), func(f func(IDType)) {
+		for id, z := range k.db.Zones {
+			if z != nil {
+				f(id)
+			}
+		}
+	})
+}
+
+func (k *dbChecker) trashIDs() []IDType {
+	return sortedIDs(len(k.db.Trash), func(f func(IDType)) {
+		for id, t := range k.db.Trash {
+			if t != nil && t.Object() != nil {
+				f(id)
+			}
+		}
+	})
+}
+
+// exits returns every exit in the world that isn't in the trash.
+func (k *dbChecker) exits() map[IDType]*Exit {
+	r := make(map[IDType]*Exit)
+	for _, room := range k.db.Rooms {
+		for _, e := range room.Exits {
+			if e != nil {
+				r[e.ID] = e
+			}
+		}
+	}
+	return r
+}
+
+// inTrash returns true if the object with the given ID has been destroyed but can still be restored.
+func (k *dbChecker) inTrash(id IDType) bool {
+	for _, t := range k.db.Trash {
+		if o := t.Object(); o != nil && o.ObjectID() == id {
+			return true
+		}
+		if t.Room != nil {
+			for _, e := range t.Room.Exits {
+				if e != nil && e.ID == id {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkMaps makes sure that every map exists and doesn't hold nil values.
+func (k *dbChecker) checkMaps() {
+	db := k.db
+	if db.Players == nil && k.report(0, true, "Player table is missing") {
+		db.Players = make(map[IDType]*Player)
+	}
+	if db.Rooms == nil && k.report(0, true, "Room table is missing") {
+		db.Rooms = make(map[IDType]*Room)
+	}
+	if db.Items == nil && k.report(0, true, "Item table is missing") {
+		db.Items = make(map[IDType]*Item)
+	}
+	if db.Auth == nil && k.report(0, true, "Password table is missing") {
+		db.Auth = make(map[IDType]PasswordHash)
+	}
+	if db.TOTP == nil && k.report(0, true, "Two-factor authentication table is missing") {
+		db.TOTP = make(map[IDType]string)
+	}
+	if db.Trash == nil && k.report(0, true, "Trash is missing") {
+		db.Trash = make(map[IDType]*Trash)
+	}
+	if db.Zones == nil && k.report(0, true, "Zone table is missing") {
+		db.Zones = make(map[IDType]*Zone)
+	}
+	for id, z := range db.Zones {
+		if z == nil && k.report(id, true, "Zone is empty") {
+			delete(db.Zones, id)
+		}
+	}
+	for id, p := range db.Players {
+		if p == nil && k.report(id, true, "Player is empty") {
+			delete(db.Players, id)
+		}
+	}
+	for id, r := range db.Rooms {
+		if r == nil && k.report(id, true, "Room is empty") {
+			delete(db.Rooms, id)
+		}
+	}
+	for id, i := range db.Items {
+		if i == nil && k.report(id, true, "Item is empty") {
+			delete(db.Items, id)
+		}
+	}
+	for id, t := range db.Trash {
+		if (t == nil || t.Object() == nil) && k.report(id, true, "Trash entry is empty") {
+			delete(db.Trash, id)
+		}
+	}
+}
+
+// checkNextID makes sure that NextID is higher than every ID in use.
+func (k *dbChecker) checkNextID() {
+	var max IDType
+	see := func(ids ...IDType) {
+		for _, id := range ids {
+			if id > max {
+				max = id
+			}
+		}
+	}
+	for id, p := range k.db.Players {
+		if p != nil {
+			see(id, p.ID)
+		}
+	}
+	for id, r := range k.db.Rooms {
+		if r != nil {
+			see(id, r.ID)
+			for _, e := range r.Exits {
+				if e != nil {
+					see(e.ID)
+				}
+			}
+		}
+	}
+	for id, i := range k.db.Items {
+		if i != nil {
+			see(id, i.ID)
+		}
+	}
+	for id, z := range k.db.Zones {
+		if z != nil {
+			see(id, z.ID)
+		}
+	}
+	for id, t := range k.db.Trash {
+		if t == nil {
+			continue
+		}
+		see(id)
+		if o := t.Object(); o != nil {
+			see(o.ObjectID())
+		}
+		if t.Room != nil {
+			for _, e := range t.Room.Exits {
+				if e != nil {
+					see(e.ID)
+				}
+			}
+		}
+	}
+	if k.db.NextID <= max && k.report(k.db.NextID, true, "NextID is not higher than the highest ID in use, %s", max) {
+		k.db.NextID = max + 1
+	}
+}
+
+// checkIDs makes sure that each object and zone is stored under its own ID and that no two of them share an ID.
+// When two objects share an ID, the one found later is renumbered. Players are never renumbered, and zones always are.
+func (k *dbChecker) checkIDs() {
+	seen := make(map[IDType]Object)
+	use := func(o Object, id *IDType) {
+		if other, ok := seen[*id]; ok && other != o {
+			if k.report(*id, true, "%s %s has the same ID as %s %s", o.ObjectType(), o.ObjectName(), other.ObjectType(), other) {
+				*id = k.nextID()
+			}
+		}
+		seen[*id] = o
+	}
+	for _, id := range k.playerIDs() {
+		p := k.db.Players[id]
+		if p.ID != id && k.report(id, true, "Player %s is stored under the wrong ID", p) {
+			p.ID = id
+		}
+		seen[p.ID] = p
+	}
+	checkExits := func(r *Room) {
+		for _, e := range r.Exits {
+			if e != nil {
+				use(e, &e.ID)
+			}
+		}
+	}
+	for _, id := range k.roomIDs() {
+		r := k.db.Rooms[id]
+		if r.ID != id && k.report(id, true, "Room %s is stored under the wrong ID", r) {
+			r.ID = id
+		}
+		use(r, &r.ID)
+		if k.fix && r.ID != id {
+			delete(k.db.Rooms, id)
+			k.db.Rooms[r.ID] = r
+		}
+		checkExits(r)
+	}
+	for _, id := range k.itemIDs() {
+		i := k.db.Items[id]
+		if i.ID != id && k.report(id, true, "Item %s is stored under the wrong ID", i) {
+			i.ID = id
+		}
+		use(i, &i.ID)
+		if k.fix && i.ID != id {
+			delete(k.db.Items, id)
+			k.db.Items[i.ID] = i
+		}
+	}
+	for _, id := range k.trashIDs() {
+		t := k.db.Trash[id]
+		var p *IDType
+		switch {
+		case t.Room != nil:
+			p = &t.Room.ID
+		case t.Exit != nil:
+			p = &t.Exit.ID
+		case t.Item != nil:
+			p = &t.Item.ID
+		}
+		if *p != id && k.report(id, true, "%s %s in the trash is stored under the wrong ID", t.Object().ObjectType(), t.Object()) {
+			*p = id
+		}
+		use(t.Object(), p)
+		if k.fix && *p != id {
+			delete(k.db.Trash, id)
+			k.db.Trash[*p] = t
+		}
+		if t.Room != nil {
+			checkExits(t.Room)
+		}
+	}
+	for _, id := range k.zoneIDs() {
+		z := k.db.Zones[id]
+		if z.ID != id && k.report(id, true, "Zone %s is stored under the wrong ID", z) {
+			z.ID = id
+		}
+		if other, ok := seen[z.ID]; ok && k.report(z.ID, true, "Zone %s has the same ID as %s %s", z, other.ObjectType(), other) {
+			z.ID = k.nextID()
+			delete(k.db.Zones, id)
+			k.db.Zones[z.ID] = z
+			k.moveZone(id, z.ID)
+		}
+	}
+}
+
+// moveZone moves the rooms in a zone, including the rooms in the trash, to the zone's new ID.
+func (k *dbChecker) moveZone(from IDType, to IDType) {
+	rooms := make([]*Room, 0, len(k.db.Rooms))
+	for _, r := range k.db.Rooms {
+		rooms = append(rooms, r)
+	}
+	for _, t := range k.db.Trash {
+		if t != nil {
+			rooms = append(rooms, t.Room)
+		}
+	}
+	for _, r := range rooms {
+		if r != nil && r.Zone == from {
+			r.Zone = to
+		}
+	}
+}
+
+// checkDefaultRoom makes sure that DefaultRoom exists, creating one if the world doesn't have any rooms.
+func (k *dbChecker) checkDefaultRoom() {
+	if k.db.Rooms[k.db.DefaultRoom] != nil {
+		return
+	}
+	if !k.report(k.db.DefaultRoom, true, "DefaultRoom doesn't exist") {
+		return
+	}
+	if ids := k.roomIDs(); len(ids) > 0 {
+		k.db.DefaultRoom = ids[0]
+		return
+	}
+	r := &Room{ID: k.nextID(), Name: "Limbo", Attributes: make(map[string]string)}
+	k.db.Rooms[r.ID] = r
+	k.db.DefaultRoom = r.ID
+}
+
+// checkExits makes sure that each exit knows which room it is in and leads somewhere that exists.
+func (k *dbChecker) checkExits() {
+	for _, id := range k.roomIDs() {
+		r := k.db.Rooms[id]
+		exits := make([]*Exit, 0, len(r.Exits))
+		for _, e := range r.Exits {
+			if e == nil {
+				if !k.report(r.ID, true, "Room %s has an empty exit", r) {
+					exits = append(exits, e)
+				}
+				continue
+			}
+			exits = append(exits, e)
+			if e.Room != r.ID && k.report(e.ID, true, "Exit %s says that it is in %s, but it is in %s", e, e.Room, r) {
+				e.Room = r.ID
+			}
+			if e.Destination != 0 && k.db.Rooms[e.Destination] == nil {
+				if k.inTrash(e.Destination) {
+					k.report(e.ID, false, "Exit %s in %s leads to %s, which is in the trash", e, r, e.Destination)
+				} else if k.report(e.ID, true, "Exit %s in %s leads to %s, which doesn't exist", e, r, e.Destination) {
+					e.Destination = 0
+				}
+			}
+		}
+		r.Exits = exits
+	}
+}
+
+// locationExists returns true if the location is a room, player, or item that exists.
+func (k *dbChecker) locationExists(loc Location) bool {
+	switch loc.Type {
+	case LocationRoom:
+		return k.db.Rooms[loc.ID] != nil
+	case LocationPlayer:
+		return k.db.Players[loc.ID] != nil
+	case LocationItem:
+		return k.db.Items[loc.ID] != nil
+	}
+	return false
+}
+
+// checkLocations makes sure that every player and item is somewhere that exists,
+// and that no item is inside itself. Lost players and items are sent to DefaultRoom.
+func (k *dbChecker) checkLocations() {
+	home := Location{ID: k.db.DefaultRoom, Type: LocationRoom}
+	for _, id := range k.playerIDs() {
+		p := k.db.Players[id]
+		if (p.Location.Type == LocationPlayer || !k.locationExists(p.Location)) && k.report(p.ID, true, "Player %s is lost in %s", p, p.Location) {
+			p.Location = home
+		}
+	}
+	for _, id := range k.itemIDs() {
+		i := k.db.Items[id]
+		if !k.locationExists(i.Location) && k.report(i.ID, true, "Item %s is lost in %s", i, i.Location) {
+			i.Location = home
+		}
+	}
+	for _, id := range k.itemIDs() {
+		i := k.db.Items[id]
+		seen := map[IDType]bool{i.ID: true}
+		for loc := i.Location; loc.Type == LocationItem; {
+			if seen[loc.ID] {
+				if loc.ID == i.ID && k.report(i.ID, true, "Item %s is inside itself", i) {
+					i.Location = home
+				}
+				break
+			}
+			seen[loc.ID] = true
+			c := k.db.Items[loc.ID]
+			if c == nil {
+				break
+			}
+			loc = c.Location
+		}
+	}
+}
+
+// checkOwners clears owners and editors that aren't players.
+// Things without an owner can only be changed by staff.
+func (k *dbChecker) checkOwners() {
+	check := func(o Object, owner *IDType, editors *[]IDType) {
+		if *owner != 0 && k.db.Players[*owner] == nil && k.report(o.ObjectID(), true, "%s %s is owned by %s, who doesn't exist", o.ObjectType(), o, *owner) {
+			*owner = 0
+		}
+		r := make([]IDType, 0, len(*editors))
+		for _, id := range *editors {
+			if k.db.Players[id] != nil || !k.report(o.ObjectID(), true, "%s %s can be edited by %s, who doesn't exist", o.ObjectType(), o, id) {
+				r = append(r, id)
+			}
+		}
+		if len(r) != len(*editors) {
+			*editors = r
+		}
+	}
+	for _, id := range k.roomIDs() {
+		r := k.db.Rooms[id]
+		check(r, &r.Owner, &r.Editors)
+		for _, e := range r.Exits {
+			if e != nil {
+				check(e, &e.Owner, &e.Editors)
+			}
+		}
+	}
+	for _, id := range k.itemIDs() {
+		i := k.db.Items[id]
+		check(i, &i.Owner, &i.Editors)
+	}
+}
+
+// checkHomes clears homes that no longer exist. Players can only live in rooms, and items can also live with players.
+func (k *dbChecker) checkHomes() {
+	for _, id := range k.playerIDs() {
+		p := k.db.Players[id]
+		if p.Home != 0 && k.db.Rooms[p.Home] == nil && !k.inTrash(p.Home) && k.report(p.ID, true, "Player %s lives in %s, which isn't a room", p, p.Home) {
+			p.Home = 0
+		}
+	}
+	for _, id := range k.itemIDs() {
+		i := k.db.Items[id]
+		if i.Home != 0 && k.db.Rooms[i.Home] == nil && k.db.Players[i.Home] == nil && !k.inTrash(i.Home) && k.report(i.ID, true, "Item %s lives in %s, which isn't a room or player", i, i.Home) {
+			i.Home = 0
+		}
+	}
+}
+
+// checkZones makes sure that each room's zone exists and that zones are owned and built by players who exist.
+func (k *dbChecker) checkZones() {
+	for _, id := range k.roomIDs() {
+		r := k.db.Rooms[id]
+		if r.Zone != 0 && k.db.Zones[r.Zone] == nil && k.report(r.ID, true, "Room %s is in %s, which isn't a zone", r, r.Zone) {
+			r.Zone = 0
+		}
+	}
+	for id, z := range k.db.Zones {
+		if z == nil {
+			continue
+		}
+		if z.Owner != 0 && k.db.Players[z.Owner] == nil && k.report(id, true, "Zone %s is owned by %s, who doesn't exist", z, z.Owner) {
+			z.Owner = 0
+		}
+		builders := make([]IDType, 0, len(z.Builders))
+		for _, b := range z.Builders {
+			if k.db.Players[b] != nil || !k.report(id, true, "Zone %s has the builder %s, who doesn't exist", z, b) {
+				builders = append(builders, b)
+			}
+		}
+		if len(builders) != len(z.Builders) {
+			z.Builders = builders
+		}
+	}
+}
+
+// checkParents makes sure that each parent exists, is the same kind of thing, and doesn't lead back to the child.
+func (k *dbChecker) checkParents() {
+	exits := k.exits()
+	find := func(t ObjectType, id IDType) Object {
+		switch t {
+		case ObjectRoom:
+			if r := k.db.Rooms[id]; r != nil {
+				return r
+			}
+		case ObjectExit:
+			if e := exits[id]; e != nil {
+				return e
+			}
+		case ObjectItem:
+			if i := k.db.Items[id]; i != nil {
+				return i
+			}
+		}
+		return nil
+	}
+	check := func(o Object) {
+		p := o.parent()
+		if *p == 0 {
+			return
+		}
+		if find(o.ObjectType(), *p) == nil {
+			if k.inTrash(*p) {
+				k.report(o.ObjectID(), false, "%s %s has the parent %s, which is in the trash", o.ObjectType(), o, *p)
+			} else if k.report(o.ObjectID(), true, "%s %s has the parent %s, which isn't a %s", o.ObjectType(), o, *p, strings.ToLower(o.ObjectType().String())) {
+				*p = 0
+			}
+			return
+		}
+		seen := map[IDType]bool{o.ObjectID(): true}
+		for x := find(o.ObjectType(), *p); x != nil; x = find(o.ObjectType(), *x.parent()) {
+			if seen[x.ObjectID()] {
+				if x.ObjectID() == o.ObjectID() && k.report(o.ObjectID(), true, "%s %s is its own ancestor", o.ObjectType(), o) {
+					*p = 0
+				}
+				break
+			}
+			seen[x.ObjectID()] = true
+		}
+	}
+	for _, id := range k.roomIDs() {
+		r := k.db.Rooms[id]
+		check(r)
+		for _, e := range r.Exits {
+			if e != nil {
+				check(e)
+			}
+		}
+	}
+	for _, id := range k.itemIDs() {
+		check(k.db.Items[id])
+	}
+}
+
+// checkAuth removes passwords and two-factor secrets that belong to players who don't exist.
+func (k *dbChecker) checkAuth() {
+	for id := range k.db.Auth {
+		if k.db.Players[id] == nil && k.report(id, true, "Password belongs to a player who doesn't exist") {
+			delete(k.db.Auth, id)
+		}
+	}
+	for id := range k.db.TOTP {
+		if k.db.Players[id] == nil && k.report(id, true, "Two-factor secret belongs to a player who doesn't exist") {
+			delete(k.db.TOTP, id)
+		}
+	}
+}
+
+// CheckWorldFile checks the saved world state without starting the server.
+// If fix is set and any problems are fixed, the repaired world is saved.
+// The server must not be running, or it will overwrite the repairs the next time it saves.
+func CheckWorldFile(fix bool) ([]Problem, error) {
+	w, err := LoadWorld()
+	if err != nil {
+		return nil, err
+	}
+	problems := w.db.Check(fix)
+	for _, p := range problems {
+		if p.Fixed {
+			return problems, w.saveState()
+		}
+	}
+	return problems, nil
+}
+
+// CheckDatabase executes the "@dbck [fix]" command.
+func (c *Connection) CheckDatabase(fix bool) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	ack := make(chan []Problem)
+	c.Server.World.Check <- CheckMessage{Fix: fix, Ack: ack}
+	problems := <-ack
+	if len(problems) == 0 {
+		c.Printf("No problems found.\n")
+		return
+	}
+	s := fmt.Sprintf("Found %d problems:\n", len(problems))
+	fixed := 0
+	for _, p := range problems {
+		s += p.String() + "\n"
+		if p.Fixed {
+			fixed++
+		}
+	}
+	if fix {
+		c.Audit("dbck", 0, "", "", fmt.Sprintf("%d fixed", fixed))
+		s += fmt.Sprintf("Fixed %d problems.\n", fixed)
+	} else {
+		s += "Use '@dbck fix' to fix them.\n"
+	}
+	c.Printf("%s\n", s)
+}
@@ -0,0 +1,86 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	w := NewWorld()
+	home := w.db.Rooms[w.db.DefaultRoom]
+	p := &Player{ID: w.nextID(), Location: Location{ID: 999, Type: LocationRoom}, Home: 995}
+	w.db.Players[p.ID] = p
+	a := &Item{ID: w.nextID(), Owner: 998}
+	b := &Item{ID: w.nextID(), Location: Location{ID: a.ID, Type: LocationItem}}
+	a.Location = Location{ID: b.ID, Type: LocationItem}
+	w.db.Items[a.ID] = a
+	w.db.Items[b.ID] = b
+	e := &Exit{ID: w.nextID(), Room: home.ID, Destination: 997}
+	home.Exits = append(home.Exits, e)
+	w.db.Auth[996] = PasswordHash{}
+
+	problems := w.db.Check(false)
+	if len(problems) != 7 {
+		t.Fatalf("Check(false) found %d problems, but we expected 7: %v", len(problems), problems)
+	}
+	for _, x := range problems {
+		if x.Fixed {
+			t.Errorf("Check(false) fixed a problem: %s", x)
+		}
+	}
+
+	w.db.Check(true)
+	if p.Location.ID != home.ID || a.Location.ID != home.ID || a.Owner != 0 || e.Destination != 0 || p.Home != 0 {
+		t.Errorf("Check(true) didn't fix the lost player and item, orphaned item, broken exit, or missing home.")
+	}
+	if b.Location.ID != a.ID {
+		t.Errorf("Check(true) moved the item inside the box.")
+	}
+	if _, ok := w.db.Auth[996]; ok {
+		t.Errorf("Check(true) didn't remove the password of a player who doesn't exist.")
+	}
+	if problems := w.db.Check(false); len(problems) != 0 {
+		t.Errorf("Check(false) found problems after they were fixed: %v", problems)
+	}
+}
+
+func TestCheckIDs(t *testing.T) {
+	w := NewWorld()
+	r := &Room{ID: w.nextID()}
+	w.db.Rooms[r.ID] = r
+	i := &Item{ID: r.ID, Location: Location{ID: r.ID, Type: LocationRoom}}
+	w.db.Items[i.ID] = i
+	z := &Zone{ID: r.ID, Name: "Old Town"}
+	w.db.Zones[z.ID] = z
+	r.Zone = z.ID
+	w.db.NextID = 1
+
+	if problems := w.db.Check(false); len(problems) != 3 {
+		t.Errorf("Check(false) found %d problems, but we expected 3: %v", len(problems), problems)
+	}
+	w.db.Check(true)
+	if w.db.NextID <= i.ID || i.ID == r.ID || w.db.Items[i.ID] != i {
+		t.Errorf("Check(true) didn't fix NextID and renumber the item that shares an ID with a room.")
+	}
+	if z.ID == r.ID || z.ID == i.ID || w.db.Zones[z.ID] != z || r.Zone != z.ID {
+		t.Errorf("Check(true) didn't renumber the zone that shares an ID with a room and move the room along with it.")
+	}
+}
@@ -0,0 +1,159 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"strings"
+)
+
+// escapeFormat escapes a string so that it can be used as part of a format string.
+func escapeFormat(s string) string {
+	return strings.Replace(s, "%", "%%", -1)
+}
+
+// FindPlayersByLocation is a helper method that returns a slice of all players in a given location, whether or not they are online.
+func (c *Connection) FindPlayersByLocation(loc Location) []*Player {
+	ack := make(chan []*Player)
+	c.Server.World.FindPlayer <- FindPlayerMessage{Location: &loc, Ack: ack}
+	return <-ack
+}
+
+// Enter executes the "enter" command and moves the player inside an enterable item.
+func (c *Connection) Enter(target string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	foundOne, foundMany := c.FindLocalThing(c.Player.Location, target, false)
+	if len(foundMany) > 0 {
+		c.Printf("Which thing did you mean?\n")
+		for _, t := range foundMany {
+			c.Printf("%s\n", t)
+		}
+		return
+	}
+	i, ok := foundOne.(*Item)
+	switch {
+	case foundOne == nil:
+		c.Printf("That is not here.\n")
+	case !ok || !i.Enterable || i.Location != c.Player.Location:
+		c.Printf("You can't enter that.\n")
+	case c.passesLock(i.Lock):
+		n := escapeFormat(i.Name)
+		c.Move(Location{ID: i.ID, Type: LocationItem}, "%s climbs into "+n+".", "%s climbs in.")
+	}
+}
+
+// Leave executes the "leave" command and moves the player out of the item they are in.
+func (c *Connection) Leave() {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	if c.Player.Location.Type != LocationItem {
+		c.Printf("You're not inside anything.\n")
+		return
+	}
+	i := c.FindItemByID(c.Player.Location.ID)
+	if i == nil {
+		// The item is gone, so send the player somewhere safe.
+		c.Move(c.defaultLocation(), "%s vanishes.", "%s appears suddenly.")
+		return
+	}
+	n := escapeFormat(i.Name)
+	c.Move(i.Location, "%s climbs out.", "%s climbs out of "+n+".")
+}
+
+// lookInterior describes the inside of an enterable item.
+// Players inside a vehicle can also see the room outside.
+func (c *Connection) lookInterior(i *Item) string {
+	if c == nil || c.Player == nil || !c.Authenticated || i == nil {
+		return ""
+	}
+	loc := Location{ID: i.ID, Type: LocationItem}
+	s := fmt.Sprintf("Inside %s\n", i)
+	if i.Interior != "" {
+		s += i.Interior + "\n"
+	} else {
+		d, _ := c.inheritedDescription(i)
+		s += d + "\n"
+	}
+	for _, item := range c.FindItemsByLocation(loc) {
+		s += fmt.Sprintf("You see %s here.\n", item.Name)
+	}
+	for _, player := range c.FindOnlinePlayersByLocation(&loc) {
+		if player.ID != c.Player.ID {
+			s += fmt.Sprintf("You see %s here.\n", player.Name)
+		}
+	}
+	if i.Vehicle && i.Location.Type == LocationRoom {
+		r := c.FindRoomByID(i.Location.ID)
+		if r != nil {
+			s += "\nThrough the window you can see:\n"
+			s += c.lookRoom(r)
+		}
+	}
+	return s
+}
+
+// Drive moves the vehicle the player is in through an exit in the room the vehicle is in.
+// Everybody inside the vehicle is carried along with it.
+func (c *Connection) Drive(v *Item, target string) {
+	if c == nil || !c.Authenticated || c.Player == nil || v == nil {
+		return
+	}
+	if v.Location.Type != LocationRoom {
+		c.Printf("%s can't go anywhere from here.\n", v.Name)
+		return
+	}
+	r := c.FindRoomByID(v.Location.ID)
+	if r == nil {
+		c.Printf("You're Lost!\n")
+		return
+	}
+	e := c.findExit(r, target)
+	if e == nil {
+		c.Printf("%s can't go that way.\n", v.Name)
+		return
+	}
+	dest := c.FindRoomByID(e.Destination)
+	if dest == nil {
+		c.Printf("That doesn't seem to go anywhere.\n")
+		return
+	}
+	if e.Locked {
+		c.Printf("The %s is locked.\n", e.Name)
+		return
+	}
+	if !c.passesLock(e.Lock) || !c.passesLock(dest.Lock) {
+		return
+	}
+	from := v.Location
+	to := Location{ID: dest.ID, Type: LocationRoom}
+	c.LocationPrintf(&from, "%s leaves through the %s.\n", v.Name, e.Name)
+	v.Location = to
+	c.LocationPrintf(&to, "%s arrives.\n", v.Name)
+	inside := Location{ID: v.ID, Type: LocationItem}
+	for _, conn := range c.Server.PlayerConnections() {
+		if conn.InLocation(&inside) {
+			conn.Printf("%s moves %s.\n", v.Name, e.Name)
+			conn.Look("")
+		}
+	}
+}
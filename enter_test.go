@@ -0,0 +1,80 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+func TestEnterLeave(t *testing.T) {
+	w := NewWorld()
+	hall := w.db.Rooms[w.db.DefaultRoom]
+	garage := &Room{ID: w.nextID(), Name: "Garage"}
+	street := &Room{ID: w.nextID(), Name: "Street"}
+	w.db.Rooms[garage.ID] = garage
+	w.db.Rooms[street.ID] = street
+	out := &Exit{ID: w.nextID(), Name: "out", Room: garage.ID, Destination: street.ID}
+	garage.Exits = append(garage.Exits, out)
+	inGarage := Location{ID: garage.ID, Type: LocationRoom}
+	p := &Player{ID: w.nextID(), Name: "Driver", Location: inGarage}
+	w.db.Players[p.ID] = p
+	car := &Item{ID: w.nextID(), Name: "car", Location: inGarage, Enterable: true, Vehicle: true}
+	box := &Item{ID: w.nextID(), Name: "box", Location: inGarage}
+	w.db.Items[car.ID] = car
+	w.db.Items[box.ID] = box
+	c := testConnection(testServer(t, w), p)
+	inCar := Location{ID: car.ID, Type: LocationItem}
+
+	c.Enter("box")
+	if p.Location != inGarage {
+		t.Errorf("Entering something that isn't enterable moved the player to %v.", p.Location)
+	}
+	c.Enter("car")
+	if p.Location != inCar {
+		t.Fatalf("Entering the car moved the player to %v, but we expected %v.", p.Location, inCar)
+	}
+
+	c.Drive(car, "out")
+	inStreet := Location{ID: street.ID, Type: LocationRoom}
+	if car.Location != inStreet || p.Location != inCar {
+		t.Errorf("Driving out left the car in %v and the player in %v, but we expected the car in %v with the player inside.", car.Location, p.Location, inStreet)
+	}
+	c.Drive(car, "nowhere")
+	if car.Location != inStreet {
+		t.Errorf("Driving through an exit that doesn't exist moved the car to %v.", car.Location)
+	}
+
+	c.Leave()
+	if p.Location != inStreet {
+		t.Errorf("Leaving the car moved the player to %v, but we expected %v.", p.Location, inStreet)
+	}
+	c.Leave()
+	if p.Location != inStreet {
+		t.Errorf("Leaving while not inside anything moved the player to %v.", p.Location)
+	}
+
+	// If the car is gone, the player is sent to the default room.
+	p.Location = inCar
+	delete(w.db.Items, car.ID)
+	c.Leave()
+	if want := (Location{ID: hall.ID, Type: LocationRoom}); p.Location != want {
+		t.Errorf("Leaving a car that is gone moved the player to %v, but we expected %v.", p.Location, want)
+	}
+}
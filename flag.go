@@ -0,0 +1,168 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Flags is a set of flags on an object.
+type Flags uint32
+
+const (
+	// FlagWizard means that a player is an administrator.
+	FlagWizard Flags = 1 << iota
+	// FlagBuilder means that a player is allowed to build.
+	FlagBuilder
+	// FlagDark means that a room's contents can't be seen, or that a player, item, or exit isn't listed in its room.
+	FlagDark
+	// FlagSafe means that an object can't be destroyed until the flag is removed.
+	FlagSafe
+	// FlagNoTel means that an object can't be teleported, or that a room can't be teleported into.
+	FlagNoTel
+	// FlagSticky means that an item goes home when it is dropped.
+	FlagSticky
+	// FlagTransparent means that players can see through an exit into the room on the other side.
+	FlagTransparent
+	// FlagHidden means that an exit is hidden until it is found with the "search" command.
+	FlagHidden
+	// FlagAttached means that an item can only be picked up by its owner.
+	FlagAttached
+	// FlagLinkOK means that anybody can link exits to a room or set it as their home.
+	FlagLinkOK
+)
+
+// wizardFlags can only be set or cleared by admins.
+const wizardFlags = FlagWizard | FlagBuilder
+
+var flagNames = map[Flags]string{
+	FlagWizard:      "WIZARD",
+	FlagBuilder:     "BUILDER",
+	FlagDark:        "DARK",
+	FlagSafe:        "SAFE",
+	FlagNoTel:       "NO_TEL",
+	FlagSticky:      "STICKY",
+	FlagTransparent: "TRANSPARENT",
+	FlagHidden:      "HIDDEN",
+	FlagAttached:    "ATTACHED",
+	FlagLinkOK:      "LINK_OK",
+}
+
+// Has returns true if all of the given flags are set.
+func (f Flags) Has(flag Flags) bool {
+	return f&flag == flag
+}
+
+func (f Flags) String() string {
+	names := make([]string, 0)
+	for flag, n := range flagNames {
+		if f&flag != 0 {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+// ParseFlag parses the name of a flag.
+func ParseFlag(s string) (Flags, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	for f, n := range flagNames {
+		if n == s || strings.Replace(n, "_", "", -1) == s {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown flag: %s", s)
+}
+
+// ParseFlags parses a list of flag names separated by spaces.
+func ParseFlags(s string) (Flags, error) {
+	var r Flags
+	for _, n := range strings.Fields(s) {
+		f, err := ParseFlag(n)
+		if err != nil {
+			return 0, err
+		}
+		r |= f
+	}
+	return r, nil
+}
+
+// setFlag sets or clears a flag and returns the result.
+func setFlag(flags Flags, flag Flags, on bool) Flags {
+	if on {
+		return flags | flag
+	}
+	return flags &^ flag
+}
+
+// canSetFlags returns true if the player can change the thing's flags from old to new.
+// Only admins can change wizardFlags, and giving them to a player follows the same rules as giving out a role.
+func (c *Connection) canSetFlags(t Object, old Flags, new Flags) bool {
+	if !c.canEdit(t, "flags") {
+		return false
+	}
+	if (old^new)&wizardFlags == 0 {
+		return true
+	}
+	if !c.IsAdmin() {
+		return false
+	}
+	if t.ObjectType() == ObjectPlayer {
+		if r := flagRole(new &^ old); r != 0 && !c.canGiveRole(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetFlag executes the "@set <target>=[!]<flag>" command.
+// A flag starting with "!" is cleared instead of set.
+func (c *Connection) SetFlag(target string, flag string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	flag = strings.TrimSpace(flag)
+	clear := strings.HasPrefix(flag, "!")
+	f, err := ParseFlag(strings.TrimPrefix(flag, "!"))
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	flags := t.flags()
+	old := *flags
+	if !c.canSetFlags(t, old, setFlag(old, f, !clear)) {
+		c.Printf("Can't set %s on %s.\n", flagNames[f], t)
+		return
+	}
+	*flags = setFlag(*flags, f, !clear)
+	c.changed(t, "flag", "flags", old.String(), flags.String())
+	if clear {
+		c.Printf("%s cleared on %s.\n", flagNames[f], t)
+	} else {
+		c.Printf("%s set on %s.\n", flagNames[f], t)
+	}
+}
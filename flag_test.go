@@ -0,0 +1,86 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+func TestParseFlag(t *testing.T) {
+	tests := []struct {
+		s string
+		f Flags
+		e bool
+	}{
+		{"DARK", FlagDark, false},
+		{"wizard", FlagWizard, false},
+		{" no_tel ", FlagNoTel, false},
+		{"NOTEL", FlagNoTel, false},
+		{"link_ok", FlagLinkOK, false},
+		{"", 0, true},
+		{"FLYING", 0, true},
+	}
+	for _, x := range tests {
+		f, err := ParseFlag(x.s)
+		if err != nil && !x.e {
+			t.Errorf("ParseFlag(%q) threw an error when it shouldn't have.", x.s)
+		} else if err == nil && x.e {
+			t.Errorf("ParseFlag(%q) = %s, but we expected an error.", x.s, f)
+		} else if f != x.f {
+			t.Errorf("ParseFlag(%q) = %s, but we expected %s.", x.s, f, x.f)
+		}
+	}
+}
+
+func TestFlagsString(t *testing.T) {
+	f := setFlag(FlagSticky|FlagDark, FlagSafe, true)
+	f = setFlag(f, FlagDark, false)
+	if s := f.String(); s != "SAFE STICKY" {
+		t.Errorf("Flags.String() = %q, but we expected %q.", s, "SAFE STICKY")
+	}
+}
+
+func TestUpgradeFlags(t *testing.T) {
+	w := NewWorld()
+	p := &Player{ID: w.nextID(), Admin: true}
+	w.db.Players[p.ID] = p
+	p2 := &Player{ID: w.nextID(), Admin: true}
+	w.db.Players[p2.ID] = p2
+	i := &Item{ID: w.nextID(), Attached: true}
+	w.db.Items[i.ID] = i
+	e := w.db.Rooms[w.db.DefaultRoom].Exits[0]
+	e.Hidden = true
+	w.upgrade()
+	if !p.Flags.Has(FlagWizard) || p.Admin {
+		t.Errorf("Admin wasn't upgraded to WIZARD.")
+	}
+	if p.Role != RoleGod {
+		t.Errorf("The first admin is a %s, but we expected a god.", p.Role)
+	}
+	if p2.Role == RoleGod {
+		t.Errorf("Every admin was upgraded to a god.")
+	}
+	if !i.Flags.Has(FlagAttached) || i.Attached {
+		t.Errorf("Attached wasn't upgraded to ATTACHED.")
+	}
+	if !e.Flags.Has(FlagHidden) || e.Hidden {
+		t.Errorf("Hidden wasn't upgraded to HIDDEN.")
+	}
+}
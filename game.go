@@ -56,13 +56,25 @@ func ParseID(s string) (IDType, error) {
 }
 
 // Player represents a player in the world.
+// Home is the room that the "home" command takes the player to. If it is 0, or the room is gone, the default room is used.
 type Player struct {
-	ID          IDType
-	Name        string
-	Description string
-	Location    Location
-	Admin       bool
-	LastActed   time.Time
+	ID            IDType
+	Name          string
+	Description   string
+	Location      Location
+	Home          IDType
+	Role          Role
+	Quota         map[ObjectType]int
+	Flags         Flags
+	LastActed     time.Time
+	LastTOTPStep  uint64
+	Discovered    map[IDType]bool
+	Attributes    map[string]string
+	AttributeInfo map[string]AttributeInfo
+	History       []Revision
+
+	// Deprecated: Admin is only read when loading an old world. Use FlagWizard instead.
+	Admin bool
 }
 
 func (p *Player) String() string {
@@ -74,12 +86,19 @@ func (p *Player) String() string {
 
 // Room represents a room in the world.
 type Room struct {
-	ID          IDType
-	Name        string
-	Description string
-	Exits       []*Exit
-	Owner       IDType
-	Attributes  map[string]string
+	ID            IDType
+	Name          string
+	Description   string
+	Exits         []*Exit
+	Owner         IDType
+	Editors       []IDType
+	Parent        IDType
+	Zone          IDType
+	Lock          Lock
+	Flags         Flags
+	Attributes    map[string]string
+	AttributeInfo map[string]AttributeInfo
+	History       []Revision
 }
 
 func (r *Room) String() string {
@@ -90,35 +109,85 @@ func (r *Room) String() string {
 }
 
 // Exit represents an exit between two rooms.
+// Exits with FlagHidden are only shown to players who have found them with the "search" command.
+// If SearchLock is set, a player finds the exit when they pass the lock expression.
+// Otherwise SearchChance is the percent chance of finding it on each search, and 0 uses the server's default.
 type Exit struct {
 	ID              IDType
 	Name            string
+	Room            IDType
+	Aliases         []string
 	Description     string
 	LongDescription string
 	Destination     IDType
 	ArriveMessage   string
 	LeaveMessage    string
 	Owner           IDType
-	Hidden          bool
+	Editors         []IDType
+	SearchChance    int
+	SearchLock      string
 	Lockable        bool
 	Locked          bool
 	Key             IDType
+	Parent          IDType
+	Lock            Lock
+	Flags           Flags
 	Attributes      map[string]string
+	AttributeInfo   map[string]AttributeInfo
+	History         []Revision
+
+	// Deprecated: Hidden is only read when loading an old world. Use FlagHidden instead.
+	Hidden bool
 }
 
 func (e *Exit) String() string {
 	return fmt.Sprintf("%s [%s]", e.Name, e.ID)
 }
 
+// Matches returns true if the given name is the exit's name or one of its aliases.
+func (e *Exit) Matches(name string) bool {
+	n := strings.TrimSpace(strings.ToLower(name))
+	if n == "" {
+		return false
+	}
+	if strings.ToLower(e.Name) == n {
+		return true
+	}
+	for _, a := range e.Aliases {
+		if strings.ToLower(a) == n {
+			return true
+		}
+	}
+	return false
+}
+
 // Item represents an item in the world.
+// Items with Container set can hold other items. A Capacity of 0 means there is no limit.
+// Players can climb inside Enterable items, where they see the Interior description.
+// A Vehicle is an enterable item that carries everybody inside it when it moves.
+// Home is the room or player that the item returns to, and a STICKY item returns there when it is dropped.
 type Item struct {
-	ID          IDType
-	Name        string
-	Description string
-	Owner       IDType
-	Location    Location
-	Attached    bool
-	Attributes  map[string]string
+	ID            IDType
+	Name          string
+	Description   string
+	Owner         IDType
+	Editors       []IDType
+	Location      Location
+	Home          IDType
+	Container     bool
+	Capacity      int
+	Enterable     bool
+	Vehicle       bool
+	Interior      string
+	Parent        IDType
+	Lock          Lock
+	Flags         Flags
+	Attributes    map[string]string
+	AttributeInfo map[string]AttributeInfo
+	History       []Revision
+
+	// Deprecated: Attached is only read when loading an old world. Use FlagAttached instead.
+	Attached bool
 }
 
 func (i *Item) String() string {
@@ -173,6 +242,9 @@ type WorldDatabase struct {
 	Rooms       map[IDType]*Room
 	Items       map[IDType]*Item
 	Auth        map[IDType]PasswordHash
+	TOTP        map[IDType]string
+	Trash       map[IDType]*Trash
+	Zones       map[IDType]*Zone
 }
 
 // World contains a WorldDatabase and all of the channels needed to modify it.
@@ -180,6 +252,9 @@ type World struct {
 	// Data
 	db WorldDatabase
 
+	// trashRetention is how long destroyed objects are kept. A negative value keeps them until they are restored.
+	trashRetention time.Duration
+
 	// Channels
 
 	FindPlayer    chan FindPlayerMessage
@@ -190,6 +265,7 @@ type World struct {
 	NewRoom     chan NewRoomMessage
 	DestroyRoom chan DestroyRoomMessage
 
+	FindExit    chan FindExitMessage
 	NewExit     chan NewExitMessage
 	DestroyExit chan DestroyExitMessage
 
@@ -197,11 +273,27 @@ type World struct {
 	NewItem     chan NewItemMessage
 	DestroyItem chan DestroyItemMessage
 
+	Clone chan CloneMessage
+	Usage chan UsageMessage
+
+	FindTrash chan FindTrashMessage
+	Undestroy chan UndestroyMessage
+
+	Check chan CheckMessage
+
+	FindZone     chan FindZoneMessage
+	NewZone      chan NewZoneMessage
+	TransferZone chan TransferZoneMessage
+
 	SaveWorldState chan SaveWorldStateMessage
 	Shutdown       chan bool
 
 	CheckPassword chan PasswordMessage
 	SetPassword   chan PasswordMessage
+
+	HasTOTP   chan TOTPMessage
+	CheckTOTP chan TOTPMessage
+	SetTOTP   chan TOTPMessage
 }
 
 // NewWorld creates a new World instance
@@ -214,7 +306,11 @@ func NewWorld() *World {
 			Players:     make(map[IDType]*Player),
 			Items:       make(map[IDType]*Item),
 			Auth:        make(map[IDType]PasswordHash),
+			TOTP:        make(map[IDType]string),
+			Trash:       make(map[IDType]*Trash),
+			Zones:       make(map[IDType]*Zone),
 		},
+		trashRetention: -1,
 
 		FindPlayer:    make(chan FindPlayerMessage),
 		NewPlayer:     make(chan NewPlayerMessage),
@@ -224,6 +320,7 @@ func NewWorld() *World {
 		NewRoom:     make(chan NewRoomMessage),
 		DestroyRoom: make(chan DestroyRoomMessage),
 
+		FindExit:    make(chan FindExitMessage),
 		NewExit:     make(chan NewExitMessage),
 		DestroyExit: make(chan DestroyExitMessage),
 
@@ -231,11 +328,27 @@ func NewWorld() *World {
 		NewItem:     make(chan NewItemMessage),
 		DestroyItem: make(chan DestroyItemMessage),
 
+		Clone: make(chan CloneMessage),
+		Usage: make(chan UsageMessage),
+
+		FindTrash: make(chan FindTrashMessage),
+		Undestroy: make(chan UndestroyMessage),
+
+		Check: make(chan CheckMessage),
+
+		FindZone:     make(chan FindZoneMessage),
+		NewZone:      make(chan NewZoneMessage),
+		TransferZone: make(chan TransferZoneMessage),
+
 		SaveWorldState: make(chan SaveWorldStateMessage),
 		Shutdown:       make(chan bool),
 
 		CheckPassword: make(chan PasswordMessage),
 		SetPassword:   make(chan PasswordMessage),
+
+		HasTOTP:   make(chan TOTPMessage),
+		CheckTOTP: make(chan TOTPMessage),
+		SetTOTP:   make(chan TOTPMessage),
 	}
 
 	r := &Room{
@@ -259,6 +372,7 @@ func NewWorld() *World {
 	r.Exits = append(r.Exits, &Exit{
 		ID:              w.nextID(),
 		Name:            "down",
+		Room:            r.ID,
 		Description:     "Stairs spiral down from here to the cellar.",
 		LongDescription: "You can see the flicker of firelight coming up from below.",
 		Destination:     r2.ID,
@@ -270,6 +384,7 @@ func NewWorld() *World {
 	r2.Exits = append(r2.Exits, &Exit{
 		ID:              w.nextID(),
 		Name:            "up",
+		Room:            r2.ID,
 		Description:     "Stairs spiral up from here to the main lobby.",
 		LongDescription: "You can see light shining down from above and you hear the sound of people talking.",
 		Destination:     r.ID,
@@ -303,44 +418,68 @@ type NewPlayerMessage struct {
 }
 
 // DestroyPlayerMessage is sent to DestroyPlayer to destroy a given player.
+// The things that the player owned are given to Heir, or moved into the trash if Heir is 0.
+// Things that are left without a location are moved to Fallback. By is the player who destroyed them.
 type DestroyPlayerMessage struct {
-	ID  IDType
-	Ack chan bool
+	ID       IDType
+	By       IDType
+	Heir     IDType
+	Fallback IDType
+	Ack      chan *Cascade
 }
 
 // FindRoomMessage is sent to FindRoom to find a set of rooms.
+// If Default is set, the default room is found.
 type FindRoomMessage struct {
-	ID    IDType
-	Owner IDType
-	Ack   chan []*Room
+	ID      IDType
+	Owner   IDType
+	Zone    IDType
+	Default bool
+	Ack     chan []*Room
 }
 
 // NewRoomMessage is sent to NewRoom to create a new room.
+// Quota is the most rooms that the owner can have, and a negative value means that there is no limit.
 type NewRoomMessage struct {
 	Name  string
 	Owner IDType
+	Quota int
 	Ack   chan *Room
 }
 
 // DestroyRoomMessage is sent to DestroyRoom to destroy a room.
+// The room, its exits, and the exits leading into it are moved into the trash,
+// and everything inside it is moved to Fallback. By is the player who destroyed it.
 type DestroyRoomMessage struct {
-	Room IDType
-	ID   IDType
-	Ack  chan bool
+	Room     IDType
+	ID       IDType
+	By       IDType
+	Fallback IDType
+	Ack      chan *Cascade
+}
+
+// FindExitMessage is sent to FindExit to find an exit.
+type FindExitMessage struct {
+	ID  IDType
+	Ack chan []*Exit
 }
 
 // NewExitMessage is sent to NewExit to create a new exit.
+// Quota is the most exits that the owner can have, and a negative value means that there is no limit.
 type NewExitMessage struct {
 	Room  IDType
 	Name  string
 	Owner IDType
+	Quota int
 	Ack   chan *Exit
 }
 
 // DestroyExitMessage is sent to DestroyExit to destroy an exit.
+// The exit is moved into the trash. By is the player who destroyed it.
 type DestroyExitMessage struct {
 	Room IDType
 	ID   IDType
+	By   IDType
 	Ack  chan bool
 }
 
@@ -353,15 +492,19 @@ type FindItemMessage struct {
 }
 
 // NewItemMessage is sent to NewItem to create a new item.
+// Quota is the most items that the owner can have, and a negative value means that there is no limit.
 type NewItemMessage struct {
 	Name  string
 	Owner IDType
+	Quota int
 	Ack   chan *Item
 }
 
 // DestroyItemMessage is sent to DestroyItem to destroy an item.
+// The item is moved into the trash. By is the player who destroyed it.
 type DestroyItemMessage struct {
 	ID  IDType
+	By  IDType
 	Ack chan bool
 }
 
@@ -378,12 +521,24 @@ type PasswordMessage struct {
 	Ack      chan bool
 }
 
+// TOTPMessage is sent to HasTOTP to see if a player has enrolled in two-factor authentication,
+// CheckTOTP to check a TOTP code, and SetTOTP to set a player's TOTP secret.
+// When setting a secret, Code is the code that the player confirmed it with.
+// Setting an empty secret removes the player's enrollment.
+type TOTPMessage struct {
+	ID     IDType
+	Secret string
+	Code   string
+	Ack    chan bool
+}
+
 // WorldThread returns a goroutine that handles World events.
 func (w *World) WorldThread() func() {
 	return func() {
 		log.Println("World Thread Started")
 		defer log.Println("World Thread Stopped")
 		saveTimer := time.NewTicker(SaveStateFrequency).C
+		trashTimer := time.NewTicker(PurgeTrashFrequency).C
 		for {
 			select {
 			case e := <-w.FindPlayer:
@@ -414,17 +569,13 @@ func (w *World) WorldThread() func() {
 					},
 				}
 				if len(w.db.Players) == 0 {
-					p.Admin = true
+					p.Role = RoleGod
+					p.Flags |= FlagWizard
 				}
 				w.db.Players[p.ID] = p
 				e.Ack <- p
 			case e := <-w.DestroyPlayer:
-				if e.ID == 1 {
-					e.Ack <- false
-				}
-				log.Printf("Destroy Player: %d\n", e.ID)
-				delete(w.db.Players, e.ID)
-				e.Ack <- true
+				e.Ack <- w.destroyPlayer(e.ID, e.By, e.Heir, e.Fallback)
 			case e := <-w.FindRoom:
 				r := make([]*Room, 0)
 				if e.ID > 0 {
@@ -434,9 +585,19 @@ func (w *World) WorldThread() func() {
 					}
 				} else if e.Owner > 0 {
 					r = w.findRoomByOwner(e.Owner)
+				} else if e.Zone > 0 {
+					r = w.findRoomByZone(e.Zone)
+				} else if e.Default {
+					if v := w.db.Rooms[w.db.DefaultRoom]; v != nil {
+						r = append(r, v)
+					}
 				}
 				e.Ack <- r
 			case e := <-w.NewRoom:
+				if !w.withinQuota(e.Owner, map[ObjectType]int{ObjectRoom: e.Quota}, map[ObjectType]int{ObjectRoom: 1}) {
+					e.Ack <- nil
+					break
+				}
 				log.Printf("New Room: %s\n", e.Name)
 				id := w.nextID()
 				r := &Room{
@@ -448,22 +609,29 @@ func (w *World) WorldThread() func() {
 				w.db.Rooms[r.ID] = r
 				e.Ack <- r
 			case e := <-w.DestroyRoom:
-				if e.ID == 1 {
-					e.Ack <- false
+				e.Ack <- w.destroyRoom(e.ID, e.By, e.Fallback)
+			case e := <-w.FindExit:
+				r := make([]*Exit, 0)
+				ex := w.findExitByID(e.ID)
+				if ex != nil {
+					r = append(r, ex)
 				}
-				log.Printf("Destroy Room: %d\n", e.ID)
-				delete(w.db.Rooms, e.ID)
-				e.Ack <- true
+				e.Ack <- r
 			case e := <-w.NewExit:
-				log.Printf("New Exit: %s\n", e.Name)
-				id := w.nextID()
+				if !w.withinQuota(e.Owner, map[ObjectType]int{ObjectExit: e.Quota}, map[ObjectType]int{ObjectExit: 1}) {
+					e.Ack <- nil
+					break
+				}
 				r := w.db.Rooms[e.Room]
 				if r == nil {
 					e.Ack <- nil
+					break
 				}
+				log.Printf("New Exit: %s\n", e.Name)
 				ex := &Exit{
-					ID:         id,
+					ID:         w.nextID(),
 					Name:       e.Name,
+					Room:       r.ID,
 					Owner:      e.Owner,
 					Attributes: make(map[string]string),
 				}
@@ -474,15 +642,19 @@ func (w *World) WorldThread() func() {
 				r := w.db.Rooms[e.Room]
 				if r == nil {
 					e.Ack <- false
+					break
 				}
+				found := false
 				for i, ex := range r.Exits {
 					if ex != nil && ex.ID == e.ID {
 						r.Exits[i] = nil
 						r.Exits = append(r.Exits[:i], r.Exits[i+1:]...)
+						w.trash(&Trash{DestroyedBy: e.By, Exit: ex})
+						found = true
 						break
 					}
 				}
-				e.Ack <- true
+				e.Ack <- found
 			case e := <-w.FindItem:
 				r := make([]*Item, 0)
 				if e.ID > 0 {
@@ -497,6 +669,10 @@ func (w *World) WorldThread() func() {
 				}
 				e.Ack <- r
 			case e := <-w.NewItem:
+				if !w.withinQuota(e.Owner, map[ObjectType]int{ObjectItem: e.Quota}, map[ObjectType]int{ObjectItem: 1}) {
+					e.Ack <- nil
+					break
+				}
 				log.Printf("New Item: %s\n", e.Name)
 				id := w.nextID()
 				i := &Item{
@@ -512,9 +688,35 @@ func (w *World) WorldThread() func() {
 				w.db.Items[i.ID] = i
 				e.Ack <- i
 			case e := <-w.DestroyItem:
+				i := w.db.Items[e.ID]
+				if i == nil {
+					e.Ack <- false
+					break
+				}
 				log.Printf("Destroy Item: %d\n", e.ID)
 				delete(w.db.Items, e.ID)
+				w.trash(&Trash{DestroyedBy: e.By, Item: i})
 				e.Ack <- true
+			case e := <-w.Clone:
+				e.Ack <- w.clone(e)
+			case e := <-w.Usage:
+				e.Ack <- w.usage(e.Owner)
+			case e := <-w.FindTrash:
+				e.Ack <- w.findTrash(e)
+			case e := <-w.Undestroy:
+				e.Ack <- w.undestroy(e)
+			case e := <-w.Check:
+				e.Ack <- w.db.Check(e.Fix)
+			case e := <-w.FindZone:
+				e.Ack <- w.findZones(e)
+			case e := <-w.NewZone:
+				e.Ack <- w.newZone(e)
+			case e := <-w.TransferZone:
+				e.Ack <- w.transferZone(e)
+			case <-trashTimer:
+				if w.trashRetention >= 0 {
+					w.purgeTrash(time.Now().Add(-w.trashRetention))
+				}
 			case e := <-w.SaveWorldState:
 				e.Ack <- w.saveState()
 			case <-saveTimer:
@@ -539,6 +741,14 @@ func (w *World) WorldThread() func() {
 				// log.Printf("SetPassword - ID: %s, Password: %s\n", e.ID, e.Password)
 				w.db.Auth[e.ID] = hashPassword(e.Password)
 				e.Ack <- true
+			case e := <-w.HasTOTP:
+				_, ok := w.db.TOTP[e.ID]
+				e.Ack <- ok
+			case e := <-w.CheckTOTP:
+				e.Ack <- w.checkTOTP(e.ID, e.Code, time.Now())
+			case e := <-w.SetTOTP:
+				w.setTOTP(e, time.Now())
+				e.Ack <- true
 			}
 		}
 	}
@@ -579,6 +789,17 @@ func (w *World) findRoomByOwner(id IDType) []*Room {
 	return r
 }
 
+func (w *World) findExitByID(id IDType) *Exit {
+	for _, r := range w.db.Rooms {
+		for _, e := range r.Exits {
+			if e != nil && e.ID == id {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
 func (w *World) findItemByLocation(loc Location) []*Item {
 	r := make([]*Item, 0)
 	for _, i := range w.db.Items {
@@ -647,7 +868,56 @@ func LoadWorld() (*World, error) {
 		log.Printf("ERROR: Could not load world state: %s\n", err.Error())
 		return nil, err
 	}
+	w.upgrade()
 	// log.Printf("State Loaded: %+v", w)
 	log.Printf("State Loaded\n")
 	return w, nil
 }
+
+// upgrade fills in fields that were added after the world state was saved.
+func (w *World) upgrade() {
+	if w.db.Trash == nil {
+		w.db.Trash = make(map[IDType]*Trash)
+	}
+	if w.db.Zones == nil {
+		w.db.Zones = make(map[IDType]*Zone)
+	}
+	var god *Player
+	for _, p := range w.db.Players {
+		if p.Admin {
+			p.Flags |= FlagWizard
+			p.Admin = false
+		}
+		if p.Role == RoleGod {
+			god = p
+		}
+	}
+	if god == nil {
+		// Worlds saved before roles existed don't have a god, so the first admin becomes one.
+		for _, p := range w.db.Players {
+			if p.Flags.Has(FlagWizard) && (god == nil || p.ID < god.ID) {
+				god = p
+			}
+		}
+		if god != nil {
+			god.Role = RoleGod
+		}
+	}
+	for _, r := range w.db.Rooms {
+		for _, e := range r.Exits {
+			if e != nil {
+				e.Room = r.ID
+				if e.Hidden {
+					e.Flags |= FlagHidden
+					e.Hidden = false
+				}
+			}
+		}
+	}
+	for _, i := range w.db.Items {
+		if i.Attached {
+			i.Flags |= FlagAttached
+			i.Attached = false
+		}
+	}
+}
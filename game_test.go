@@ -62,3 +62,120 @@ func TestPasswordHash(t *testing.T) {
 		t.Errorf("hashPassword(%s) = %v, but we expected %v.", s, h, correctHash)
 	}
 }
+
+func TestExitMatches(t *testing.T) {
+	e := &Exit{Name: "north", Aliases: parseAliases("n; forward;;")}
+	for _, n := range []string{"north", "North", "n", " forward "} {
+		if !e.Matches(n) {
+			t.Errorf("Exit.Matches(%q) = false, but we expected true.", n)
+		}
+	}
+	for _, n := range []string{"", "south", "nor", ";"} {
+		if e.Matches(n) {
+			t.Errorf("Exit.Matches(%q) = true, but we expected false.", n)
+		}
+	}
+}
+
+func TestObjects(t *testing.T) {
+	w := NewWorld()
+	r := w.db.Rooms[w.db.DefaultRoom]
+	objects := []struct {
+		o Object
+		t ObjectType
+		l Location
+	}{
+		{&Player{ID: 10, Location: Location{ID: r.ID, Type: LocationRoom}}, ObjectPlayer, Location{ID: r.ID, Type: LocationRoom}},
+		{r, ObjectRoom, Location{}},
+		{r.Exits[0], ObjectExit, Location{ID: r.ID, Type: LocationRoom}},
+		{&Item{ID: 11, Location: Location{ID: 10, Type: LocationPlayer}}, ObjectItem, Location{ID: 10, Type: LocationPlayer}},
+	}
+	for _, x := range objects {
+		if x.o.ObjectType() != x.t {
+			t.Errorf("%s.ObjectType() = %s, but we expected %s.", x.o, x.o.ObjectType(), x.t)
+		}
+		if x.o.ObjectLocation() != x.l {
+			t.Errorf("%s.ObjectLocation() = %s, but we expected %s.", x.o, x.o.ObjectLocation(), x.l)
+		}
+		if values, infos := x.o.ObjectAttributes(); values == nil || infos == nil {
+			t.Errorf("%s.ObjectAttributes() returned a nil map.", x.o)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	w := NewWorld()
+	hall := w.db.Rooms[w.db.DefaultRoom]
+	cellar := &Room{ID: w.nextID(), Name: "Cellar"}
+	w.db.Rooms[cellar.ID] = cellar
+	owner := &Player{ID: w.nextID(), Name: "Owner"}
+	p := &Player{ID: w.nextID(), Name: "Seeker", Location: Location{ID: hall.ID, Type: LocationRoom}}
+	w.db.Players[owner.ID] = owner
+	w.db.Players[p.ID] = p
+	key := &Item{ID: w.nextID(), Name: "lamp", Location: Location{ID: hall.ID, Type: LocationRoom}}
+	w.db.Items[key.ID] = key
+	trapdoor := &Exit{ID: w.nextID(), Name: "trapdoor", Room: hall.ID, Destination: cellar.ID, Owner: owner.ID, Flags: FlagHidden, SearchLock: key.ID.String()}
+	crack := &Exit{ID: w.nextID(), Name: "crack", Room: hall.ID, Destination: cellar.ID, Owner: owner.ID, Flags: FlagHidden, SearchChance: 100}
+	hall.Exits = append(hall.Exits, trapdoor, crack)
+	s := testServer(t, w)
+	c := testConnection(s, p)
+
+	if c.CanSeeExit(trapdoor) || c.CanSeeExit(crack) {
+		t.Errorf("The player can see hidden exits before searching.")
+	}
+	if !testConnection(s, owner).CanSeeExit(trapdoor) {
+		t.Errorf("The owner can't see their own hidden exit.")
+	}
+
+	// The trapdoor's search lock fails until the player picks up the lamp.
+	c.Search()
+	if !p.Discovered[crack.ID] || p.Discovered[trapdoor.ID] {
+		t.Errorf("After searching, crack found = %v and trapdoor found = %v, but we expected only the crack.", p.Discovered[crack.ID], p.Discovered[trapdoor.ID])
+	}
+	if !c.CanSeeExit(crack) {
+		t.Errorf("The player can't see an exit that they found.")
+	}
+	key.Location = Location{ID: p.ID, Type: LocationPlayer}
+	c.Search()
+	if !p.Discovered[trapdoor.ID] || !c.CanSeeExit(trapdoor) {
+		t.Errorf("Searching while carrying the lamp didn't find the trapdoor.")
+	}
+}
+
+func TestSetSearchChance(t *testing.T) {
+	w := NewWorld()
+	p := &Player{ID: w.nextID(), Role: RoleBuilder}
+	w.db.Players[p.ID] = p
+	e := &Exit{ID: w.nextID(), Name: "crack", Room: w.db.DefaultRoom, Destination: w.db.DefaultRoom, Owner: p.ID}
+	w.db.Rooms[w.db.DefaultRoom].Exits = append(w.db.Rooms[w.db.DefaultRoom].Exits, e)
+	c := testConnection(testServer(t, w), p)
+
+	for _, x := range []struct {
+		v string
+		n int
+	}{
+		{"50", 50},
+		{"0", 50},
+		{"101", 50},
+		{"default", 0},
+		{"100", 100},
+	} {
+		c.setExit(e, "searchchance", x.v)
+		if e.SearchChance != x.n {
+			t.Errorf("After setting searchchance to %q, SearchChance = %d, but we expected %d.", x.v, e.SearchChance, x.n)
+		}
+	}
+}
+
+// testServer starts the world's thread and returns a server for it.
+// The thread is stopped when the test finishes.
+func testServer(t *testing.T, w *World) *Server {
+	go w.WorldThread()()
+	t.Cleanup(func() { w.Shutdown <- true })
+	return &Server{cm: NewConnectionManager(), World: w, Config: DefaultConfig()}
+}
+
+// testConnection returns a logged in connection for the given player.
+func testConnection(s *Server, p *Player) *Connection {
+	return &Connection{Player: p, Server: s, Authenticated: true}
+}
@@ -0,0 +1,186 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revision records a change to one field of an object.
+// Attribute changes use the attribute's name with a leading "&" as the field.
+type Revision struct {
+	Number int
+	Time   time.Time
+	Actor  IDType
+	Field  string
+	Old    string
+	New    string
+}
+
+func (r Revision) String() string {
+	return fmt.Sprintf("#%d %s %s %s: %q -> %q", r.Number, r.Time.Format(time.RFC3339), r.Actor, r.Field, r.Old, r.New)
+}
+
+// addRevision appends a revision to a history, numbering it after the last one.
+// Only the newest max revisions are kept, unless max is negative.
+func addRevision(h []Revision, r Revision, max int) []Revision {
+	r.Number = 1
+	if len(h) > 0 {
+		r.Number = h[len(h)-1].Number + 1
+	}
+	h = append(h, r)
+	if max >= 0 && len(h) > max {
+		h = append([]Revision(nil), h[len(h)-max:]...)
+	}
+	return h
+}
+
+// historyField returns the name that a field set with the "set" command is recorded under.
+// Fields that are stored as flags are recorded as "flags".
+func historyField(field string) string {
+	f := strings.TrimSpace(strings.ToLower(field))
+	if fieldAliases[f] == "Flags" {
+		return "flags"
+	}
+	return f
+}
+
+// changed records a change to a thing in the audit log and in the thing's history.
+func (c *Connection) changed(t Object, action string, field string, oldValue string, newValue string) {
+	c.Audit(action, t.ObjectID(), field, oldValue, newValue)
+	max := -1
+	if c.Server != nil && c.Server.Config != nil {
+		max = c.Server.Config.HistorySize
+	}
+	h := t.history()
+	*h = addRevision(*h, Revision{
+		Time:  time.Now(),
+		Actor: c.Player.ID,
+		Field: field,
+		Old:   oldValue,
+		New:   newValue,
+	}, max)
+}
+
+// ShowHistory executes the "@history <target>" command.
+func (c *Connection) ShowHistory(target string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	if !c.canEdit(t, "history") {
+		c.Printf("You can't see the history of %s.\n", t)
+		return
+	}
+	h := *t.history()
+	if len(h) == 0 {
+		c.Printf("%s hasn't been changed.\n", t)
+		return
+	}
+	s := fmt.Sprintf("History of %s:\n", t)
+	for _, r := range h {
+		s += r.String() + "\n"
+	}
+	c.Printf("%s\n", s)
+}
+
+// Revert executes the "@revert <target> <revision>" command.
+// The field changed by the revision is set back to the value it had before the revision.
+// Reverting is itself recorded as a new revision, so it can be undone.
+func (c *Connection) Revert(target string, revision string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(revision), "#"))
+	if err != nil {
+		c.Printf("Revision must be a number.\n")
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	if !c.canEdit(t, "history") {
+		c.Printf("You can't revert %s.\n", t)
+		return
+	}
+	for _, r := range *t.history() {
+		if r.Number == n {
+			field := r.Field
+			if strings.HasPrefix(field, "&") {
+				field = "attributes"
+			}
+			if !c.canEdit(t, field) {
+				c.Printf("You can't change %s on %s.\n", r.Field, t)
+				return
+			}
+			c.Printf("Reverting %s on %s to before revision #%d.\n", r.Field, t, n)
+			c.restore(t, r.Field, r.Old)
+			return
+		}
+	}
+	c.Printf("%s doesn't have a revision #%d.\n", t, n)
+}
+
+// restore sets a field recorded in a thing's history back to the given value.
+func (c *Connection) restore(t Object, field string, value string) {
+	switch {
+	case strings.HasPrefix(field, "&"):
+		c.setAttribute(t, field[1:], value)
+	case field == "flags":
+		f, err := ParseFlags(value)
+		if err != nil {
+			c.Printf("Error: %s\n", err.Error())
+			return
+		}
+		flags := t.flags()
+		if !c.canSetFlags(t, *flags, f) {
+			c.Printf("Can't set flags on %s.\n", t)
+			return
+		}
+		c.changed(t, "flag", "flags", flags.String(), f.String())
+		*flags = f
+		c.Printf("Set.\n")
+	case field == "lock":
+		l := c.editableLock(t)
+		if l == nil {
+			c.Printf("You can't lock %s.\n", t)
+			return
+		}
+		c.changed(t, "lock", "lock", l.Expression, value)
+		l.Expression = value
+		c.Printf("Set.\n")
+	case field == "parent":
+		id, err := ParseID(value)
+		if err != nil {
+			c.Printf("Error: %s\n", err.Error())
+			return
+		}
+		c.setParent(t, id)
+	default:
+		c.setThing(t, field, value)
+	}
+}
@@ -0,0 +1,118 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestAddRevision(t *testing.T) {
+	var h []Revision
+	for i := 0; i < 5; i++ {
+		h = addRevision(h, Revision{Field: "name"}, 3)
+	}
+	if len(h) != 3 {
+		t.Fatalf("History has %d revisions, but we expected 3.", len(h))
+	}
+	if h[0].Number != 3 || h[2].Number != 5 {
+		t.Errorf("History has revisions #%d to #%d, but we expected #3 to #5.", h[0].Number, h[2].Number)
+	}
+	h = addRevision(nil, Revision{}, -1)
+	if len(h) != 1 || h[0].Number != 1 {
+		t.Errorf("First revision wasn't numbered #1.")
+	}
+}
+
+func TestHistoryField(t *testing.T) {
+	tests := []struct {
+		s string
+		f string
+	}{
+		{"Name", "name"},
+		{" desc ", "desc"},
+		{"hidden", "flags"},
+		{"attached", "flags"},
+	}
+	for _, x := range tests {
+		if f := historyField(x.s); f != x.f {
+			t.Errorf("historyField(%q) = %q, but we expected %q.", x.s, f, x.f)
+		}
+	}
+}
+
+func TestRevert(t *testing.T) {
+	w := NewWorld()
+	room := w.db.Rooms[w.db.DefaultRoom]
+	p := &Player{ID: w.nextID(), Role: RoleBuilder, Location: Location{ID: room.ID, Type: LocationRoom}}
+	w.db.Players[p.ID] = p
+	other := &Room{ID: w.nextID(), Owner: p.ID}
+	w.db.Rooms[other.ID] = other
+	e := &Exit{ID: w.nextID(), Name: "door", Room: room.ID, Owner: p.ID}
+	room.Exits = append(room.Exits, e)
+	base := &Item{ID: w.nextID(), Owner: p.ID}
+	box := &Item{
+		ID:            w.nextID(),
+		Name:          "box",
+		Owner:         p.ID,
+		Location:      Location{ID: p.ID, Type: LocationPlayer},
+		Attributes:    make(map[string]string),
+		AttributeInfo: make(map[string]AttributeInfo),
+	}
+	w.db.Items[base.ID] = base
+	w.db.Items[box.ID] = box
+	c := testConnection(testServer(t, w), p)
+
+	c.setThing(e, "dest", other.ID.String())
+	if e.Destination != other.ID {
+		t.Fatalf("The door wasn't linked to %s.", other.ID)
+	}
+	c.Revert(e.ID.String(), "1")
+	if e.Destination != 0 {
+		t.Errorf("Reverting the first destination left the door linked to %s.", e.Destination)
+	}
+
+	id := box.ID.String()
+	c.setThing(box, "name", "crate")
+	c.SetFlag(id, "DARK")
+	c.SetLock(id, "#123")
+	c.setParent(box, base.ID)
+	c.setAttribute(box, "COLOR", "red")
+	if len(box.History) != 5 {
+		t.Fatalf("The box has %d revisions, but we expected 5.", len(box.History))
+	}
+	for n := 5; n > 0; n-- {
+		c.Revert(id, strconv.Itoa(n))
+	}
+	if box.Name != "box" || box.Flags != 0 || box.Lock.Expression != "" || box.Parent != 0 || len(box.Attributes) != 0 {
+		t.Errorf("Reverting every revision left the box as %+v.", box)
+	}
+	if len(box.History) != 10 {
+		t.Errorf("The box has %d revisions, but we expected the reverts to be recorded too.", len(box.History))
+	}
+
+	// Players who can't edit the door can't revert it.
+	stranger := &Player{ID: w.nextID(), Location: p.Location}
+	w.db.Players[stranger.ID] = stranger
+	testConnection(c.Server, stranger).Revert(e.ID.String(), "2")
+	if e.Destination != 0 || len(e.History) != 2 {
+		t.Errorf("Another player reverted the door to %s.", e.Destination)
+	}
+}
@@ -0,0 +1,241 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"strings"
+)
+
+// playerHome returns the room that the player calls home.
+func (c *Connection) playerHome(p *Player) Location {
+	if r := c.FindRoomByID(p.Home); r != nil {
+		return Location{ID: r.ID, Type: LocationRoom}
+	}
+	return c.defaultLocation()
+}
+
+// itemHome returns the room or player that the item returns to.
+// Items without a home go back to their owner, or to the default room if their owner is gone.
+func (c *Connection) itemHome(i *Item) Location {
+	if r := c.FindRoomByID(i.Home); r != nil {
+		return Location{ID: r.ID, Type: LocationRoom}
+	}
+	if p := c.FindPlayerByID(i.Home); p != nil {
+		return Location{ID: p.ID, Type: LocationPlayer}
+	}
+	if p := c.FindPlayerByID(i.Owner); p != nil {
+		return Location{ID: p.ID, Type: LocationPlayer}
+	}
+	return c.defaultLocation()
+}
+
+// sendHome returns an item to its home.
+func (c *Connection) sendHome(i *Item) {
+	c.LocationPrintf(&i.Location, "%s goes home.\n", i.Name)
+	i.Location = c.itemHome(i)
+	c.LocationPrintf(&i.Location, "%s appears suddenly.\n", i.Name)
+}
+
+// GoHome executes the "home" command.
+func (c *Connection) GoHome() {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	home := c.playerHome(c.Player)
+	if c.Player.Location == home {
+		c.Printf("You're already home.\n")
+		return
+	}
+	c.Move(home, "%s goes home.", "%s arrives home.")
+}
+
+// resolve finds the thing that a player is talking about.
+// Besides the names of things that are nearby, "me" is the player, "here" is where the player is,
+// and an ID can be used to find anything.
+func (c *Connection) resolve(s string) Object {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "me":
+		return c.Player
+	case "here":
+		loc := c.Player.Location
+		switch loc.Type {
+		case LocationRoom:
+			if r := c.FindRoomByID(loc.ID); r != nil {
+				return r
+			}
+		case LocationItem:
+			if i := c.FindItemByID(loc.ID); i != nil {
+				return i
+			}
+		}
+		c.Printf("You're Lost!\n")
+		return nil
+	}
+	if id, err := ParseID(s); err == nil {
+		if t := c.FindObjectByID(id); t != nil {
+			return t
+		}
+		c.Printf("%s doesn't exist.\n", id)
+		return nil
+	}
+	return c.findTarget(s)
+}
+
+// canLinkTo returns true if the player can make the given room or player a home.
+// Anybody can link to a room with FlagLinkOK.
+func (c *Connection) canLinkTo(t Object) bool {
+	switch x := t.(type) {
+	case *Room:
+		return c.CanEditRoom(x, "link")
+	case *Player:
+		return x.ID == c.Player.ID || c.CanEditPlayer(x, "link")
+	}
+	return false
+}
+
+// setHome sets the home of a player or item. Players can only live in rooms.
+func (c *Connection) setHome(t Object, value string) (IDType, bool) {
+	id, err := ParseID(value)
+	if err != nil {
+		c.Printf("Home must be an ID value of the form '@0'.\n")
+		return 0, false
+	}
+	if id == 0 {
+		return 0, true
+	}
+	h := c.FindObjectByID(id)
+	if h == nil || (h.ObjectType() != ObjectRoom && (t.ObjectType() == ObjectPlayer || h.ObjectType() != ObjectPlayer)) {
+		c.Printf("%s can't live in %s.\n", t, id)
+		return 0, false
+	}
+	if !c.canLinkTo(h) {
+		c.Printf("You don't have permission to link to %s.\n", h)
+		return 0, false
+	}
+	return id, true
+}
+
+// Link executes the "@link <target>=<destination>" command.
+// Linking a player or item sets its home, and linking an exit sets its destination.
+func (c *Connection) Link(target string, destination string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	t := c.resolve(target)
+	if t == nil {
+		return
+	}
+	d := c.resolve(destination)
+	if d == nil {
+		return
+	}
+	switch t.ObjectType() {
+	case ObjectPlayer, ObjectItem:
+		c.setThing(t, "home", d.ObjectID().String())
+	case ObjectExit:
+		c.setThing(t, "destination", d.ObjectID().String())
+	default:
+		c.Printf("You can't link %s.\n", t)
+	}
+}
+
+// Teleport executes the "@tel <target>=<destination>" command.
+// Players can teleport themselves and the things they can edit to rooms they can link to.
+// Things with FlagNoTel, and rooms with FlagNoTel, can only be teleported by admins.
+func (c *Connection) Teleport(target string, destination string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	t := c.resolve(target)
+	if t == nil {
+		return
+	}
+	d := c.resolve(destination)
+	if d == nil {
+		return
+	}
+	if t != Object(c.Player) && !c.canEdit(t, "location") {
+		c.Printf("You can't teleport %s.\n", t)
+		return
+	}
+	flags := d.ObjectFlags()
+	if r, ok := d.(*Room); ok {
+		flags = c.roomFlags(r)
+	}
+	if !c.IsAdmin() && (t.ObjectFlags().Has(FlagNoTel) || flags.Has(FlagNoTel)) {
+		c.Printf("Something prevents the teleport.\n")
+		return
+	}
+	var dest Location
+	switch x := d.(type) {
+	case *Room:
+		dest = Location{ID: x.ID, Type: LocationRoom}
+		if c.Role() < RoleStaff && !c.CanEditRoom(x, "link") {
+			c.Printf("You can't teleport to %s.\n", x)
+			return
+		}
+	case *Item:
+		dest = Location{ID: x.ID, Type: LocationItem}
+		if (t.ObjectType() == ObjectPlayer && !x.Enterable) || (t.ObjectType() == ObjectItem && !x.Container) {
+			c.Printf("%s can't go inside %s.\n", t, x)
+			return
+		}
+		if c.Role() < RoleStaff && !c.CanEditItem(x, "link") {
+			c.Printf("You can't teleport to %s.\n", x)
+			return
+		}
+		if i, ok := t.(*Item); ok && !c.canPutIn(x, i) {
+			return
+		}
+	case *Player:
+		dest = Location{ID: x.ID, Type: LocationPlayer}
+		if t.ObjectType() != ObjectItem || (x.ID != c.Player.ID && c.Role() < RoleStaff) {
+			c.Printf("You can't teleport %s to %s.\n", t, x)
+			return
+		}
+	default:
+		c.Printf("You can't teleport to %s.\n", d)
+		return
+	}
+	switch x := t.(type) {
+	case *Player:
+		c.Audit("teleport", x.ID, "location", x.Location.String(), dest.String())
+		for _, conn := range c.Server.PlayerConnections() {
+			if conn.Player != nil && conn.Authenticated && conn.Player.ID == x.ID {
+				conn.Move(dest, "%s vanishes.", "%s appears suddenly.")
+				if conn != c {
+					c.Printf("Teleported %s.\n", x)
+				}
+				return
+			}
+		}
+		x.Location = dest
+		c.Printf("Teleported %s.\n", x)
+	case *Item:
+		c.Audit("teleport", x.ID, "location", x.Location.String(), dest.String())
+		c.LocationPrintf(&x.Location, "%s vanishes.\n", x.Name)
+		x.Location = dest
+		c.LocationPrintf(&dest, "%s appears suddenly.\n", x.Name)
+		c.Printf("Teleported %s.\n", x)
+	default:
+		c.Printf("You can't teleport %s.\n", t)
+	}
+}
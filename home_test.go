@@ -0,0 +1,154 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+// homeTestWorld is a small world for testing homes and teleporting.
+type homeTestWorld struct {
+	w      *World
+	hall   *Room
+	mine   *Room
+	theirs *Room
+	open   *Room
+	p      *Player
+	q      *Player
+}
+
+func newHomeTestWorld() homeTestWorld {
+	w := NewWorld()
+	x := homeTestWorld{w: w, hall: w.db.Rooms[w.db.DefaultRoom]}
+	x.p = &Player{ID: w.nextID(), Name: "Pat", Location: Location{ID: x.hall.ID, Type: LocationRoom}}
+	x.q = &Player{ID: w.nextID(), Name: "Quinn", Location: Location{ID: x.hall.ID, Type: LocationRoom}}
+	w.db.Players[x.p.ID] = x.p
+	w.db.Players[x.q.ID] = x.q
+	x.mine = &Room{ID: w.nextID(), Owner: x.p.ID}
+	x.theirs = &Room{ID: w.nextID(), Owner: x.q.ID}
+	x.open = &Room{ID: w.nextID(), Owner: x.q.ID, Flags: FlagLinkOK}
+	for _, r := range []*Room{x.mine, x.theirs, x.open} {
+		w.db.Rooms[r.ID] = r
+	}
+	return x
+}
+
+// item adds an item to the world.
+func (x homeTestWorld) item(i *Item) *Item {
+	i.ID = x.w.nextID()
+	x.w.db.Items[i.ID] = i
+	return i
+}
+
+func TestSetHome(t *testing.T) {
+	x := newHomeTestWorld()
+	box := x.item(&Item{Owner: x.p.ID})
+	c := testConnection(testServer(t, x.w), x.p)
+
+	tests := []struct {
+		t  Object
+		s  string
+		id IDType
+		ok bool
+	}{
+		{x.p, x.mine.ID.String(), x.mine.ID, true},
+		{x.p, x.theirs.ID.String(), 0, false},
+		{x.p, x.open.ID.String(), x.open.ID, true},
+		{x.p, box.ID.String(), 0, false},
+		{x.p, "@0", 0, true},
+		{x.p, "nowhere", 0, false},
+		{box, x.p.ID.String(), x.p.ID, true},
+		{box, x.q.ID.String(), 0, false},
+		{box, x.mine.ID.String(), x.mine.ID, true},
+	}
+	for _, test := range tests {
+		id, ok := c.setHome(test.t, test.s)
+		if id != test.id || ok != test.ok {
+			t.Errorf("setHome(%s, %q) = %s, %v, but we expected %s, %v.", test.t, test.s, id, ok, test.id, test.ok)
+		}
+	}
+}
+
+func TestTeleport(t *testing.T) {
+	x := newHomeTestWorld()
+	here := Location{ID: x.p.ID, Type: LocationPlayer}
+	box := x.item(&Item{Name: "box", Owner: x.p.ID, Container: true, Capacity: 1, Location: here})
+	bag := x.item(&Item{Name: "bag", Owner: x.p.ID, Container: true})
+	bag.Location = Location{ID: box.ID, Type: LocationItem}
+	coin := x.item(&Item{Name: "coin", Owner: x.p.ID, Location: here})
+	anchor := x.item(&Item{Name: "anchor", Owner: x.p.ID, Location: here, Flags: FlagNoTel})
+	rock := x.item(&Item{Name: "rock", Owner: x.q.ID, Location: Location{ID: x.hall.ID, Type: LocationRoom}})
+	x.mine.Flags |= FlagNoTel
+	s := testServer(t, x.w)
+	c := testConnection(s, x.p)
+
+	tests := []struct {
+		name string
+		t    *Item
+		d    Object
+		loc  Location
+	}{
+		{"inside its own contents", box, bag, here},
+		{"into a full container", coin, box, here},
+		{"with NO_TEL", anchor, x.hall, here},
+		{"into a NO_TEL room", coin, x.mine, here},
+		{"into somebody else's room", coin, x.theirs, here},
+		{"that somebody else owns", rock, x.open, rock.Location},
+		{"into a container", coin, bag, Location{ID: bag.ID, Type: LocationItem}},
+	}
+	for _, test := range tests {
+		c.Teleport(test.t.ID.String(), test.d.ObjectID().String())
+		if test.t.Location != test.loc {
+			t.Errorf("Teleporting an item %s moved it to %s, but we expected %s.", test.name, test.t.Location, test.loc)
+		}
+	}
+
+	c.Teleport("me", x.open.ID.String())
+	if x.p.Location.ID != x.open.ID {
+		t.Errorf("Pat couldn't teleport to a LINK_OK room.")
+	}
+	wizard := &Player{ID: x.w.nextID(), Role: RoleWizard}
+	testConnection(s, wizard).Teleport(anchor.ID.String(), x.mine.ID.String())
+	if anchor.Location.ID != x.mine.ID {
+		t.Errorf("A wizard couldn't teleport an item with NO_TEL into a NO_TEL room.")
+	}
+}
+
+func TestDropSticky(t *testing.T) {
+	x := newHomeTestWorld()
+	here := Location{ID: x.p.ID, Type: LocationPlayer}
+	pin := x.item(&Item{Name: "pin", Owner: x.p.ID, Home: x.mine.ID, Location: here, Flags: FlagSticky})
+	cup := x.item(&Item{Name: "cup", Owner: x.p.ID, Location: here, Flags: FlagSticky})
+	pen := x.item(&Item{Name: "pen", Owner: x.p.ID, Home: x.mine.ID, Location: here})
+	c := testConnection(testServer(t, x.w), x.p)
+
+	for _, i := range []*Item{pin, cup, pen} {
+		c.Drop(i.Name)
+	}
+	if pin.Location != (Location{ID: x.mine.ID, Type: LocationRoom}) {
+		t.Errorf("The sticky pin went to %s instead of its home.", pin.Location)
+	}
+	if cup.Location != here {
+		t.Errorf("The sticky cup without a home went to %s instead of back to its owner.", cup.Location)
+	}
+	if pen.Location != x.p.Location {
+		t.Errorf("The pen went to %s instead of staying where it was dropped.", pen.Location)
+	}
+}
@@ -0,0 +1,366 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Lock restricts who can use an exit, item, or room.
+//
+// Expression is a lock expression such as "#123 | flag:builder & !attr:banned".
+// An empty expression means the object is not locked.
+// FailMessage is shown to a player who fails the lock.
+// RoomFailMessage is shown to everybody else in the room and should contain "%s" for the player's name.
+type Lock struct {
+	Expression      string
+	FailMessage     string
+	RoomFailMessage string
+}
+
+// LockContext provides the information needed to check a lock expression against a player.
+type LockContext interface {
+	// HasObject returns true if the player is, or is carrying, the object with the given ID.
+	HasObject(id IDType) bool
+	// HasFlag returns true if the player has the given flag.
+	HasFlag(flag string) bool
+	// HasAttribute returns true if the player has the given attribute.
+	// If value is not empty, the attribute must also have that value.
+	HasAttribute(name string, value string) bool
+}
+
+// LockExpr is a parsed lock expression.
+type LockExpr interface {
+	// Eval returns true if the lock expression passes for the given context.
+	Eval(ctx LockContext) bool
+	String() string
+}
+
+type lockAnd struct{ l, r LockExpr }
+
+func (e lockAnd) Eval(ctx LockContext) bool { return e.l.Eval(ctx) && e.r.Eval(ctx) }
+func (e lockAnd) String() string            { return fmt.Sprintf("(%s & %s)", e.l, e.r) }
+
+type lockOr struct{ l, r LockExpr }
+
+func (e lockOr) Eval(ctx LockContext) bool { return e.l.Eval(ctx) || e.r.Eval(ctx) }
+func (e lockOr) String() string            { return fmt.Sprintf("(%s | %s)", e.l, e.r) }
+
+type lockNot struct{ e LockExpr }
+
+func (e lockNot) Eval(ctx LockContext) bool { return !e.e.Eval(ctx) }
+func (e lockNot) String() string            { return fmt.Sprintf("!%s", e.e) }
+
+type lockObject struct{ id IDType }
+
+func (e lockObject) Eval(ctx LockContext) bool { return ctx.HasObject(e.id) }
+func (e lockObject) String() string            { return fmt.Sprintf("#%d", e.id) }
+
+type lockFlag struct{ flag string }
+
+func (e lockFlag) Eval(ctx LockContext) bool { return ctx.HasFlag(e.flag) }
+func (e lockFlag) String() string            { return "flag:" + e.flag }
+
+type lockAttribute struct{ name, value string }
+
+func (e lockAttribute) Eval(ctx LockContext) bool { return ctx.HasAttribute(e.name, e.value) }
+func (e lockAttribute) String() string {
+	if e.value != "" {
+		return fmt.Sprintf("attr:%s=%s", e.name, e.value)
+	}
+	return "attr:" + e.name
+}
+
+// ParseLock parses a lock expression.
+//
+// The grammar is:
+//
+//	expr   := term ('|' term)*
+//	term   := factor ('&' factor)*
+//	factor := '!' factor | '(' expr ')' | atom
+//	atom   := '#'<id> | '@'<id> | 'flag:'<name> | 'attr:'<name>['='<value>]
+func ParseLock(s string) (LockExpr, error) {
+	p := &lockParser{tokens: tokenizeLock(s)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty lock expression")
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in lock expression", p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+func tokenizeLock(s string) []string {
+	tokens := make([]string, 0)
+	word := ""
+	for _, r := range s {
+		switch r {
+		case '&', '|', '!', '(', ')', ' ', '\t':
+			if word != "" {
+				tokens = append(tokens, word)
+				word = ""
+			}
+			if r != ' ' && r != '\t' {
+				tokens = append(tokens, string(r))
+			}
+		default:
+			word += string(r)
+		}
+	}
+	if word != "" {
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+type lockParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *lockParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *lockParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *lockParser) parseOr() (LockExpr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "|" {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = lockOr{l, r}
+	}
+	return l, nil
+}
+
+func (p *lockParser) parseAnd() (LockExpr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&" {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = lockAnd{l, r}
+	}
+	return l, nil
+}
+
+func (p *lockParser) parseNot() (LockExpr, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return lockNot{e}, nil
+	case "(":
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ')' in lock expression")
+		}
+		return e, nil
+	}
+	return p.parseAtom()
+}
+
+func (p *lockParser) parseAtom() (LockExpr, error) {
+	t := p.next()
+	lower := strings.ToLower(t)
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of lock expression")
+	case t[0] == '#' || t[0] == '@':
+		i, err := strconv.ParseUint(t[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse ID in lock expression: %s", t)
+		}
+		return lockObject{IDType(i)}, nil
+	case strings.HasPrefix(lower, "flag:") && len(t) > 5:
+		return lockFlag{lower[5:]}, nil
+	case strings.HasPrefix(lower, "attr:") && len(t) > 5:
+		parts := strings.SplitN(t[5:], "=", 2)
+		e := lockAttribute{name: strings.ToLower(parts[0])}
+		if len(parts) > 1 {
+			e.value = parts[1]
+		}
+		if e.name == "" {
+			return nil, fmt.Errorf("missing attribute name in lock expression: %s", t)
+		}
+		return e, nil
+	}
+	return nil, fmt.Errorf("unexpected %q in lock expression", t)
+}
+
+// playerLockContext checks lock expressions against a player.
+type playerLockContext struct {
+	c *Connection
+	p *Player
+}
+
+func (ctx playerLockContext) HasObject(id IDType) bool {
+	if ctx.p.ID == id {
+		return true
+	}
+	for _, i := range ctx.c.FindItemsByLocation(Location{ID: ctx.p.ID, Type: LocationPlayer}) {
+		if i.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (ctx playerLockContext) HasFlag(flag string) bool {
+	// The builder and wizard flags check the player's role, which the flags only raise.
+	switch strings.ToLower(flag) {
+	case "admin", "wizard":
+		return ctx.p.EffectiveRole() >= RoleWizard
+	case "builder":
+		return ctx.p.EffectiveRole() >= RoleBuilder
+	}
+	f, err := ParseFlag(flag)
+	return err == nil && ctx.p.Flags.Has(f)
+}
+
+func (ctx playerLockContext) HasAttribute(name string, value string) bool {
+	v, ok := lookupAttribute(ctx.p.Attributes, name)
+	return ok && (value == "" || strings.EqualFold(v, value))
+}
+
+// evalLock returns true if the player passes the given lock expression.
+// An empty expression always passes and an invalid one always fails.
+func (c *Connection) evalLock(expression string) bool {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return false
+	}
+	if strings.TrimSpace(expression) == "" {
+		return true
+	}
+	e, err := ParseLock(expression)
+	if err != nil {
+		log.Printf("WARNING: Couldn't parse lock expression %q: %s\n", expression, err.Error())
+		return false
+	}
+	return e.Eval(playerLockContext{c: c, p: c.Player})
+}
+
+// passesLock returns true if the player passes the lock.
+// If they don't, the lock's failure messages are shown to the player and everybody else in their location.
+func (c *Connection) passesLock(l Lock) bool {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return false
+	}
+	if c.evalLock(l.Expression) {
+		return true
+	}
+	if l.FailMessage != "" {
+		c.Printf("%s\n", l.FailMessage)
+	} else {
+		c.Printf("You can't do that.\n")
+	}
+	if l.RoomFailMessage != "" {
+		for _, conn := range c.Server.PlayerConnections() {
+			if conn.Player.ID != c.Player.ID && conn.InLocation(&c.Player.Location) {
+				conn.Printf(l.RoomFailMessage+"\n", c.Player.Name)
+			}
+		}
+	}
+	return false
+}
+
+// editableLock returns the lock on the given thing if the player is allowed to change it.
+func (c *Connection) editableLock(t Object) *Lock {
+	if !c.canEdit(t, "lock") {
+		return nil
+	}
+	return t.lock()
+}
+
+// SetLock executes the "@lock" command by setting the lock expression on a target.
+func (c *Connection) SetLock(target string, expression string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	l := c.editableLock(t)
+	if l == nil {
+		c.Printf("You can't lock %s.\n", t)
+		return
+	}
+	_, err := ParseLock(expression)
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	c.changed(t, "lock", "lock", l.Expression, strings.TrimSpace(expression))
+	l.Expression = strings.TrimSpace(expression)
+	c.Printf("Locked %s: %s\n", t, l.Expression)
+}
+
+// ClearLock executes the "@unlock" command by removing the lock expression from a target.
+func (c *Connection) ClearLock(target string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	l := c.editableLock(t)
+	if l == nil {
+		c.Printf("You can't unlock %s.\n", t)
+		return
+	}
+	c.changed(t, "lock", "lock", l.Expression, "")
+	l.Expression = ""
+	c.Printf("Unlocked %s.\n", t)
+}
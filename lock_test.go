@@ -0,0 +1,129 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+type testLockContext struct {
+	objects    map[IDType]bool
+	flags      map[string]bool
+	attributes map[string]string
+}
+
+func (ctx testLockContext) HasObject(id IDType) bool { return ctx.objects[id] }
+func (ctx testLockContext) HasFlag(flag string) bool { return ctx.flags[flag] }
+func (ctx testLockContext) HasAttribute(name string, value string) bool {
+	v, ok := ctx.attributes[name]
+	return ok && (value == "" || v == value)
+}
+
+type testPairLock struct {
+	s string
+	r bool
+	e bool
+}
+
+var lockTests = []testPairLock{
+	{"#123", true, false},
+	{"@124", false, false},
+	{"#124 | flag:builder", true, false},
+	{"#123 & !attr:banned", false, false},
+	{"#123 | flag:builder & !attr:banned", true, false},
+	{"(#123 | flag:builder) & !attr:banned", false, false},
+	{"attr:color=blue & !flag:wizard", true, false},
+	{"attr:color=red", false, false},
+	{"!!#123", true, false},
+	{"", false, true},
+	{"#abc", false, true},
+	{"(#123", false, true},
+	{"#123 |", false, true},
+	{"#123 #124", false, true},
+	{"banana", false, true},
+}
+
+// TestParseLock tests parsing and evaluating lock expressions.
+func TestParseLock(t *testing.T) {
+	ctx := testLockContext{
+		objects:    map[IDType]bool{123: true},
+		flags:      map[string]bool{"builder": true},
+		attributes: map[string]string{"banned": "yes", "color": "blue"},
+	}
+	for _, x := range lockTests {
+		e, err := ParseLock(x.s)
+		if err != nil && !x.e {
+			t.Errorf("ParseLock(%q) threw an error when it shouldn't have: %s", x.s, err.Error())
+		} else if err == nil && x.e {
+			t.Errorf("ParseLock(%q) didn't throw an error when it should have.", x.s)
+		} else if err == nil && e.Eval(ctx) != x.r {
+			t.Errorf("ParseLock(%q).Eval() = %v, but we expected %v.", x.s, !x.r, x.r)
+		}
+	}
+}
+
+func TestLockExit(t *testing.T) {
+	w := NewWorld()
+	hall := w.db.Rooms[w.db.DefaultRoom]
+	p := &Player{ID: w.nextID(), Location: Location{ID: hall.ID, Type: LocationRoom}}
+	w.db.Players[p.ID] = p
+	a := &Room{ID: w.nextID(), Owner: p.ID}
+	b := &Room{ID: w.nextID(), Owner: p.ID}
+	w.db.Rooms[a.ID] = a
+	w.db.Rooms[b.ID] = b
+	key := &Item{ID: w.nextID(), Location: Location{ID: p.ID, Type: LocationPlayer}}
+	w.db.Items[key.ID] = key
+	door := &Exit{ID: w.nextID(), Name: "door", Room: a.ID, Destination: b.ID, Owner: p.ID, Lockable: true, Key: key.ID}
+	back := &Exit{ID: w.nextID(), Name: "door", Room: b.ID, Destination: a.ID, Owner: p.ID, Lockable: true}
+	a.Exits = append(a.Exits, door)
+	b.Exits = append(b.Exits, back)
+	c := testConnection(testServer(t, w), p)
+
+	// The player is standing in another room, so the counterpart has to be found from the exit's own room.
+	c.setExit(door, "locked", "true")
+	if !door.Locked || !back.Locked {
+		t.Errorf("Setting locked from another room locked the door = %v and the other side = %v, but we expected both.", door.Locked, back.Locked)
+	}
+
+	p.Location = Location{ID: a.ID, Type: LocationRoom}
+	c.LockExit("door", false)
+	if door.Locked || back.Locked {
+		t.Errorf("Unlocking with the key left the door = %v and the other side = %v, but we expected neither.", door.Locked, back.Locked)
+	}
+	key.Location = Location{ID: a.ID, Type: LocationRoom}
+	c.LockExit("door", true)
+	if door.Locked {
+		t.Errorf("The door was locked without the key.")
+	}
+
+	// A second exit leading back is told apart from the door by its key and name.
+	key.Location = Location{ID: p.ID, Type: LocationPlayer}
+	window := &Exit{ID: w.nextID(), Name: "window", Room: b.ID, Destination: a.ID, Owner: p.ID, Lockable: true}
+	b.Exits = append(b.Exits, window)
+	c.LockExit("door", true)
+	if !door.Locked || !back.Locked || window.Locked {
+		t.Errorf("Locking with two exits leading back left the door = %v, the other side = %v, and the window = %v, but we expected only the window to be unlocked.", door.Locked, back.Locked, window.Locked)
+	}
+	window.Name = "door"
+	c.LockExit("door", false)
+	if door.Locked || !back.Locked || window.Locked {
+		t.Errorf("Unlocking with an ambiguous other side left the door = %v, the other side = %v, and the window = %v, but we expected the other side to be left alone.", door.Locked, back.Locked, window.Locked)
+	}
+}
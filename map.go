@@ -0,0 +1,337 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultMapRadius is how many rooms away from the player the "map" command shows.
+const DefaultMapRadius = 3
+
+// MaxMapRadius is the largest radius that the "map" command accepts.
+const MaxMapRadius = 10
+
+// mapPoint is the position of a room on a map. X increases to the east and Y increases to the north.
+type mapPoint struct {
+	X, Y int
+}
+
+// compassDirections maps the names of exits to the direction that they lead in.
+var compassDirections = map[string]mapPoint{
+	"n":         {0, 1},
+	"north":     {0, 1},
+	"s":         {0, -1},
+	"south":     {0, -1},
+	"e":         {1, 0},
+	"east":      {1, 0},
+	"w":         {-1, 0},
+	"west":      {-1, 0},
+	"ne":        {1, 1},
+	"northeast": {1, 1},
+	"nw":        {-1, 1},
+	"northwest": {-1, 1},
+	"se":        {1, -1},
+	"southeast": {1, -1},
+	"sw":        {-1, -1},
+	"southwest": {-1, -1},
+}
+
+// exitDirection returns the compass direction that an exit leads in, based on its name and aliases.
+func exitDirection(e *Exit) (mapPoint, bool) {
+	for _, n := range append([]string{e.Name}, e.Aliases...) {
+		n = strings.Replace(strings.ToLower(strings.TrimSpace(n)), " ", "", -1)
+		n = strings.Replace(n, "-", "", -1)
+		if d, ok := compassDirections[n]; ok {
+			return d, true
+		}
+	}
+	return mapPoint{}, false
+}
+
+// roomCoordinates returns the position given by a room's COORDS attribute, which has the form "x,y".
+func roomCoordinates(r *Room) (mapPoint, bool) {
+	v, ok := lookupAttribute(r.Attributes, "coords")
+	if !ok {
+		return mapPoint{}, false
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) < 2 {
+		return mapPoint{}, false
+	}
+	x, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return mapPoint{}, false
+	}
+	y, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return mapPoint{}, false
+	}
+	return mapPoint{x, y}, true
+}
+
+// areaMap is a set of rooms laid out on a grid around a starting room.
+type areaMap struct {
+	start  *Room
+	radius int
+	rooms  map[IDType]*Room
+	at     map[mapPoint]*Room
+	pos    map[IDType]mapPoint
+	exits  func(r *Room) []*Exit
+}
+
+// layoutMap places the rooms that can be reached from the start room on a grid, going out at most radius rooms.
+// Rooms are placed using the COORDS attribute if both they and the start room have one.
+// Otherwise they are placed using the compass direction of the exit that leads to them.
+// Exits without a compass direction are followed, but the rooms they lead to aren't placed,
+// and a room is left off the map if another room is already in its place.
+// find returns a room by its ID, and exits returns the exits of a room that should be shown.
+func layoutMap(start *Room, radius int, find func(IDType) *Room, exits func(*Room) []*Exit) *areaMap {
+	m := &areaMap{
+		start:  start,
+		radius: radius,
+		rooms:  make(map[IDType]*Room),
+		at:     make(map[mapPoint]*Room),
+		pos:    make(map[IDType]mapPoint),
+		exits:  exits,
+	}
+	origin, hasOrigin := roomCoordinates(start)
+	m.place(start, mapPoint{})
+	queue := []*Room{start}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+		p := m.pos[r.ID]
+		for _, e := range exits(r) {
+			d, ok := exitDirection(e)
+			if !ok {
+				continue
+			}
+			dest := find(e.Destination)
+			if dest == nil {
+				continue
+			}
+			if _, seen := m.pos[dest.ID]; seen {
+				continue
+			}
+			next := mapPoint{p.X + d.X, p.Y + d.Y}
+			if c, ok := roomCoordinates(dest); ok && hasOrigin {
+				next = mapPoint{c.X - origin.X, c.Y - origin.Y}
+			}
+			if abs(next.X) > radius || abs(next.Y) > radius || m.at[next] != nil {
+				continue
+			}
+			m.place(dest, next)
+			queue = append(queue, dest)
+		}
+	}
+	return m
+}
+
+func (m *areaMap) place(r *Room, p mapPoint) {
+	m.rooms[r.ID] = r
+	m.at[p] = r
+	m.pos[r.ID] = p
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// render draws the map as text. Each room is drawn as "[ ]", and the player's room is drawn as "[*]".
+// Exits are drawn between rooms that are next to each other.
+func (m *areaMap) render() string {
+	size := m.radius*2 + 1
+	width, height := size*4-1, size*2-1
+	grid := make([][]byte, height)
+	for i := range grid {
+		grid[i] = []byte(strings.Repeat(" ", width))
+	}
+	put := func(row, col int, ch byte) {
+		if row < 0 || row >= height || col < 0 || col >= width {
+			return
+		}
+		if (grid[row][col] == '/' && ch == '\\') || (grid[row][col] == '\\' && ch == '/') {
+			ch = 'X'
+		}
+		grid[row][col] = ch
+	}
+	cell := func(p mapPoint) (int, int) {
+		return (m.radius - p.Y) * 2, (p.X + m.radius) * 4
+	}
+	for id, p := range m.pos {
+		row, col := cell(p)
+		mark := byte(' ')
+		if id == m.start.ID {
+			mark = '*'
+		}
+		put(row, col, '[')
+		put(row, col+1, mark)
+		put(row, col+2, ']')
+		for _, e := range m.exits(m.rooms[id]) {
+			d, ok := exitDirection(e)
+			if !ok {
+				continue
+			}
+			to := mapPoint{p.X + d.X, p.Y + d.Y}
+			if r := m.at[to]; r == nil || r.ID != e.Destination {
+				continue
+			}
+			switch d {
+			case mapPoint{1, 0}:
+				put(row, col+3, '-')
+			case mapPoint{-1, 0}:
+				put(row, col-1, '-')
+			case mapPoint{0, 1}:
+				put(row-1, col+1, '|')
+			case mapPoint{0, -1}:
+				put(row+1, col+1, '|')
+			case mapPoint{1, 1}:
+				put(row-1, col+3, '/')
+			case mapPoint{-1, -1}:
+				put(row+1, col-1, '/')
+			case mapPoint{-1, 1}:
+				put(row-1, col-1, '\\')
+			case mapPoint{1, -1}:
+				put(row+1, col+3, '\\')
+			}
+		}
+	}
+	lines := make([]string, 0, height)
+	for _, line := range grid {
+		lines = append(lines, strings.TrimRight(string(line), " "))
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	indent := width
+	for _, line := range lines {
+		if n := len(line) - len(strings.TrimLeft(line, " ")); line != "" && n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if len(line) >= indent {
+			lines[i] = line[indent:]
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// dotGraph describes a set of rooms and the exits between them as a Graphviz DOT graph.
+// Only exits that lead to rooms in the set are included.
+func dotGraph(name string, rooms []*Room) string {
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	in := make(map[IDType]bool)
+	for _, r := range rooms {
+		in[r.ID] = true
+	}
+	s := fmt.Sprintf("digraph %s {\n", strconv.Quote(name))
+	for _, r := range rooms {
+		s += fmt.Sprintf("\t%s [label=%s];\n", strconv.Quote(r.ID.String()), strconv.Quote(r.Name))
+	}
+	for _, r := range rooms {
+		for _, e := range r.Exits {
+			if e != nil && in[e.Destination] {
+				s += fmt.Sprintf("\t%s -> %s [label=%s];\n", strconv.Quote(r.ID.String()), strconv.Quote(e.Destination.String()), strconv.Quote(e.Name))
+			}
+		}
+	}
+	return s + "}\n"
+}
+
+// reachableRooms returns every room that can be reached from the start room.
+func reachableRooms(start *Room, find func(IDType) *Room) []*Room {
+	seen := map[IDType]bool{start.ID: true}
+	r := []*Room{start}
+	for i := 0; i < len(r); i++ {
+		for _, e := range r[i].Exits {
+			if e == nil || seen[e.Destination] {
+				continue
+			}
+			if dest := find(e.Destination); dest != nil {
+				seen[dest.ID] = true
+				r = append(r, dest)
+			}
+		}
+	}
+	return r
+}
+
+// ShowMap executes the "map [radius]" command.
+func (c *Connection) ShowMap(radius string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	n := DefaultMapRadius
+	if radius != "" {
+		var err error
+		n, err = strconv.Atoi(strings.TrimSpace(radius))
+		if err != nil || n < 1 || n > MaxMapRadius {
+			c.Printf("Radius must be a number from 1 to %d.\n", MaxMapRadius)
+			return
+		}
+	}
+	r := c.exitRoom()
+	if r == nil {
+		c.Printf("You need to be in a room to see a map.\n")
+		return
+	}
+	exits := func(r *Room) []*Exit {
+		visible := make([]*Exit, 0, len(r.Exits))
+		for _, e := range r.Exits {
+			if e != nil && c.CanSeeExit(e) {
+				visible = append(visible, e)
+			}
+		}
+		return visible
+	}
+	m := layoutMap(r, n, c.FindRoomByID, exits)
+	c.Printf("Map around %s:\n%s[*] is where you are.\n", r.Name, m.render())
+}
+
+// ExportMap executes the "@map [zone]" command, which prints the rooms in a zone as a Graphviz DOT graph.
+// Without a zone, the rooms that can be reached from the player's room are printed.
+func (c *Connection) ExportMap(zone string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	if strings.TrimSpace(zone) != "" {
+		if z := c.findZone(zone); z != nil {
+			c.Printf("%s", dotGraph(z.Name, c.FindRoomsByZone(z.ID)))
+		}
+		return
+	}
+	r := c.exitRoom()
+	if r == nil {
+		c.Printf("You need to be in a room to export a map.\n")
+		return
+	}
+	c.Printf("%s", dotGraph(r.Name, reachableRooms(r, c.FindRoomByID)))
+}
@@ -0,0 +1,86 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExitDirection(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases []string
+		d       mapPoint
+		ok      bool
+	}{
+		{"North", nil, mapPoint{0, 1}, true},
+		{"south-west", nil, mapPoint{-1, -1}, true},
+		{"Oak Door", []string{"e"}, mapPoint{1, 0}, true},
+		{"up", nil, mapPoint{}, false},
+	}
+	for _, x := range tests {
+		d, ok := exitDirection(&Exit{Name: x.name, Aliases: x.aliases})
+		if d != x.d || ok != x.ok {
+			t.Errorf("exitDirection(%q) = %v, %v, but we expected %v, %v.", x.name, d, ok, x.d, x.ok)
+		}
+	}
+}
+
+func TestLayoutMap(t *testing.T) {
+	rooms := make(map[IDType]*Room)
+	room := func(id IDType) *Room {
+		r := &Room{ID: id, Name: id.String(), Attributes: make(map[string]string)}
+		rooms[id] = r
+		return r
+	}
+	link := func(from *Room, name string, to *Room) {
+		from.Exits = append(from.Exits, &Exit{ID: from.ID*10 + IDType(len(from.Exits)), Name: name, Room: from.ID, Destination: to.ID})
+	}
+	a, b, c, d := room(1), room(2), room(3), room(4)
+	link(a, "east", b)
+	link(b, "west", a)
+	link(a, "north", c)
+	link(c, "south", a)
+	link(b, "northeast", d)
+	find := func(id IDType) *Room { return rooms[id] }
+	exits := func(r *Room) []*Exit { return r.Exits }
+
+	m := layoutMap(a, 1, find, exits)
+	if len(m.rooms) != 3 {
+		t.Errorf("layoutMap() placed %d rooms, but we expected 3 within the radius.", len(m.rooms))
+	}
+	expected := "[ ]\n |\n[*]-[ ]\n"
+	if s := m.render(); s != expected {
+		t.Errorf("render() = %q, but we expected %q.", s, expected)
+	}
+
+	a.Attributes["COORDS"] = "5,5"
+	c.Attributes["COORDS"] = "5,7"
+	m = layoutMap(a, 2, find, exits)
+	if p := m.pos[c.ID]; p != (mapPoint{0, 2}) {
+		t.Errorf("layoutMap() placed %s at %v, but its coordinates put it at {0 2}.", c, p)
+	}
+
+	g := dotGraph("Test", reachableRooms(a, find))
+	if !strings.Contains(g, `"@2" -> "@4" [label="northeast"];`) || strings.Count(g, "->") != 5 {
+		t.Errorf("dotGraph() = %q, but it didn't include every exit.", g)
+	}
+}
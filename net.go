@@ -28,6 +28,7 @@ import (
 	"log"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/abiosoft/ishell"
@@ -70,27 +71,39 @@ type Connection struct {
 	Connected     time.Time
 	LastActed     time.Time
 	ScriptingEnv  *ScriptingEnv
+	pendingTOTP   string
+	walkLock      sync.Mutex
+	walking       *walk
 }
 
 // Server represents a server instance.
 type Server struct {
 	cm       *ConnectionManager
 	World    *World
+	Config   *Config
+	Audit    *AuditLog
 	Shutdown chan bool
 }
 
 // NewServer creates a new Server instance.
 func NewServer() Server {
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
 	cm := NewConnectionManager()
 	go cm.ConnectionManagerThread()()
 	w, err := LoadWorld()
 	if err != nil {
 		log.Fatal(err)
 	}
+	w.trashRetention = time.Duration(cfg.TrashRetention) * time.Hour
 	go w.WorldThread()()
 	return Server{
 		cm:       cm,
 		World:    w,
+		Config:   cfg,
+		Audit:    NewAuditLog(cfg),
 		Shutdown: make(chan bool),
 	}
 }
@@ -140,7 +153,11 @@ func (s *Server) StartServer(addr string, tlsAddr string) {
 	listeners = append(listeners, s.newTCPListener(addr))
 	listeners = append(listeners, s.newTLSListener(tlsAddr))
 
+	stopAmbient := make(chan bool)
+	go s.AmbientThread(stopAmbient)
+
 	defer func() {
+		close(stopAmbient)
 		for _, l := range listeners {
 			l.Close()
 		}
@@ -213,6 +230,12 @@ func (s *Server) Connections() []*Connection {
 	return s.cm.Connections()
 }
 
+// PlayerConnections returns one connection for each player who is online.
+// Use this when sending a message to players so that players with several sessions only receive it once.
+func (s *Server) PlayerConnections() []*Connection {
+	return s.cm.PlayerConnections()
+}
+
 func (c *Connection) String() string {
 	r := c.C.RemoteAddr()
 	playerName := ""
@@ -248,7 +271,27 @@ func (c *Connection) Println(message string) {
 }
 
 // Print writes the text to the given connection without transforming it.
+// If players are allowed to have several sessions, the text is mirrored to the player's other sessions.
 func (c *Connection) Print(a ...interface{}) {
+	if c == nil {
+		return
+	}
+	for _, conn := range c.printTargets() {
+		conn.write(a...)
+	}
+}
+
+// printTargets returns the connections that text printed to the given connection is written to.
+func (c *Connection) printTargets() []*Connection {
+	r := []*Connection{c}
+	if c.Server.Config.SessionMode == SessionMulti {
+		r = append(r, c.otherSessions()...)
+	}
+	return r
+}
+
+// write writes the text to the given connection only.
+func (c *Connection) write(a ...interface{}) {
 	if c != nil && c.Shell != nil {
 		// TODO: Replace this with a channel message.
 		c.Shell.Print(a...)
@@ -260,7 +303,7 @@ func (c *Connection) LocationPrintf(loc *Location, fmt string, a ...interface{})
 	if c == nil || c.Shell == nil {
 		return
 	}
-	for _, conn := range c.Server.Connections() {
+	for _, conn := range c.Server.PlayerConnections() {
 		if conn.InLocation(loc) {
 			conn.Printf(fmt, a...)
 		}
@@ -278,8 +321,9 @@ func (c *Connection) ReadLine() string {
 // Close closes the given connection.
 func (c *Connection) Close() {
 	defer c.C.Close()
+	c.StopWalking()
 	c.Log("Connection closed")
-	if c.Authenticated && c.Player != nil {
+	if c.Authenticated && c.Player != nil && len(c.otherSessions()) == 0 {
 		c.LocationPrintf(&c.Player.Location, "%s disapears in a puff of smoke.\n", c.Player.Name)
 	}
 	ack := make(chan bool)
@@ -296,12 +340,15 @@ func connectionWorker(c *Connection) {
 		return
 	}
 	createShell(c)
+	resumed := c.takeOverSessions()
 	if isNew {
 		c.Printf("Welcome, %s!\n", c.Player.Name)
 	} else {
 		c.Printf("Welcome Back, %s!\n", c.Player.Name)
 	}
-	c.LocationPrintf(&c.Player.Location, "%s has appeared.\n", c.Player.Name)
+	if !resumed {
+		c.LocationPrintf(&c.Player.Location, "%s has appeared.\n", c.Player.Name)
+	}
 	c.Shell.ShowPrompt(true)
 	c.Shell.SetPrompt(fmt.Sprintf("%s => ", c.Player.Name))
 	addCommands(c)
@@ -309,6 +356,40 @@ func connectionWorker(c *Connection) {
 	c.Shell.Start()
 }
 
+// otherSessions returns the player's other authenticated connections.
+func (c *Connection) otherSessions() []*Connection {
+	r := make([]*Connection, 0)
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return r
+	}
+	for _, conn := range c.Server.cm.Sessions(c.Player.ID) {
+		if conn.ID != c.ID {
+			r = append(r, conn)
+		}
+	}
+	return r
+}
+
+// takeOverSessions handles any sessions the player already had open when they logged in.
+// In takeover mode the old sessions are closed, otherwise they are left open alongside the new one.
+// It returns true if the player was already connected.
+func (c *Connection) takeOverSessions() bool {
+	others := c.otherSessions()
+	if len(others) == 0 {
+		return false
+	}
+	if c.Server.Config.SessionMode == SessionMulti {
+		c.Logf("Joined %d existing session(s)", len(others))
+		return true
+	}
+	for _, conn := range others {
+		conn.write("This session has been taken over by a new login.\n")
+		conn.Log("Session taken over")
+		conn.C.Close()
+	}
+	return true
+}
+
 func createShell(c *Connection) {
 	c.Shell = ishell.NewWithConfig(&readline.Config{
 		Prompt:              "> ",
@@ -332,7 +413,7 @@ func createShell(c *Connection) {
 
 // Wall writes a string to all open connections.
 func (s *Server) Wall(format string, a ...interface{}) {
-	for _, c := range s.Connections() {
+	for _, c := range s.PlayerConnections() {
 		c.Printf(format, a...)
 	}
 }
@@ -441,12 +522,82 @@ func Login(c *Connection) (bool, error) {
 	if p == nil {
 		return false, errors.New("player not found")
 	}
+	err = c.checkSecondFactor(p, r, w)
+	if err != nil {
+		return false, err
+	}
 	c.Player = p
 	c.Authenticated = true
 	c.Log("Logged In Successfully")
 	return isNew, nil
 }
 
+// checkSecondFactor asks for a TOTP code if the player has enrolled in two-factor authentication.
+// If the configuration requires it, admins who haven't enrolled yet must do so before they can log in.
+func (c *Connection) checkSecondFactor(p *Player, r *bufio.Reader, w *bufio.Writer) error {
+	if c.hasTOTP(p.ID) {
+		i := 0
+		for {
+			i++
+			code, err := readInput("Code => ", r, w)
+			if err != nil {
+				return err
+			}
+			if c.checkTOTP(p.ID, code) {
+				return nil
+			} else if i >= 3 {
+				fmt.Fprint(w, "Authentication failed.\n")
+				w.Flush()
+				c.C.Close()
+				return fmt.Errorf("two-factor authentication failed: %s", p.Name)
+			}
+		}
+	}
+
+	if p.EffectiveRole() < RoleWizard || !c.Server.Config.RequireAdminTOTP {
+		return nil
+	}
+
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, "Admins must enroll in two-factor authentication.\n")
+	fmt.Fprint(w, "Add this URI to your authenticator app:\n")
+	fmt.Fprintf(w, "%s\n", TOTPURI(c.Server.Config.TOTPIssuer, p.Name, secret))
+	fmt.Fprintf(w, "Secret: %s\n", secret)
+	w.Flush()
+	i := 0
+	for {
+		i++
+		code, err := readInput("Code => ", r, w)
+		if err != nil {
+			return err
+		}
+		if CheckTOTP(secret, code, time.Now()) {
+			c.setTOTP(p.ID, secret, code)
+			fmt.Fprint(w, "Two-factor authentication enabled.\n")
+			w.Flush()
+			return nil
+		} else if i >= 3 {
+			fmt.Fprint(w, "Enrollment failed.\n")
+			w.Flush()
+			c.C.Close()
+			return fmt.Errorf("two-factor enrollment failed: %s", p.Name)
+		}
+	}
+}
+
+func readInput(prompt string, r *bufio.Reader, w *bufio.Writer) (string, error) {
+	fmt.Fprint(w, prompt)
+	w.Flush()
+	s, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(s), nil
+}
+
 func readPassword(prompt string, r *bufio.Reader, w *bufio.Writer) (string, error) {
 	buf := make([]byte, 0, 4096)
 	fmt.Fprintf(w, prompt)
@@ -532,7 +683,7 @@ func (c *Connection) FindPlayerByName(name string) *Player {
 // Passing in nil will return all players who are currently online.
 func (c *Connection) FindOnlinePlayersByLocation(loc *Location) []*Player {
 	players := make([]*Player, 0)
-	for _, conn := range c.Server.Connections() {
+	for _, conn := range c.Server.PlayerConnections() {
 		if conn.InLocation(loc) {
 			players = append(players, conn.Player)
 		}
@@ -545,10 +696,16 @@ func (c *Connection) NewRoom(name string, description string) *Room {
 	if c == nil || !c.Authenticated || c.Player == nil {
 		return nil
 	}
+	if !c.checkQuota(map[ObjectType]int{ObjectRoom: 1}) {
+		return nil
+	}
 	ack := make(chan *Room)
-	c.Server.World.NewRoom <- NewRoomMessage{Name: name, Owner: c.Player.ID, Ack: ack}
+	c.Server.World.NewRoom <- NewRoomMessage{Name: name, Owner: c.Player.ID, Quota: c.Quota(c.Player, ObjectRoom), Ack: ack}
 	r := <-ack
-	r.Description = description
+	if r != nil {
+		r.Description = description
+		c.Audit("create", r.ID, "", "", r.Name)
+	}
 	return r
 }
 
@@ -576,6 +733,17 @@ func (c *Connection) FindRoomByID(id IDType) *Room {
 	return rooms[0]
 }
 
+// defaultLocation is a helper method that returns the default room, which lost players and items are sent to.
+func (c *Connection) defaultLocation() Location {
+	ack := make(chan []*Room)
+	c.Server.World.FindRoom <- FindRoomMessage{Default: true, Ack: ack}
+	loc := Location{Type: LocationRoom}
+	if rooms := <-ack; len(rooms) > 0 {
+		loc.ID = rooms[0].ID
+	}
+	return loc
+}
+
 // FindRoomsByOwner is a helper method that returns a slice of rooms that belong to the given player.
 func (c *Connection) FindRoomsByOwner(id IDType) []*Room {
 	ack := make(chan []*Room)
@@ -594,33 +762,60 @@ func (c *Connection) DestroyRoom(id IDType) *Room {
 		return nil
 	}
 
-	ack := make(chan bool)
-	c.Server.World.DestroyRoom <- DestroyRoomMessage{ID: id, Ack: ack}
-	<-ack
+	ack := make(chan *Cascade)
+	c.Server.World.DestroyRoom <- DestroyRoomMessage{ID: id, By: c.Player.ID, Fallback: c.Server.Config.FallbackRoom, Ack: ack}
+	x := <-ack
+	if x == nil {
+		return nil
+	}
+	c.Audit("destroy", id, "", r.Name, "")
+	c.notifyCascade(r.Name, x)
 
 	return r
 }
 
-// NewExit is a helper method for creating a new exit.
+// NewExit is a helper method for creating a new exit in the player's current room.
 func (c *Connection) NewExit(name string, description string) *Exit {
 	if c == nil || !c.Authenticated || c.Player == nil || c.Player.Location.Type != LocationRoom {
 		return nil
 	}
-	room := c.Player.Location.ID
+	return c.NewExitInRoom(c.Player.Location.ID, name, description)
+}
+
+// NewExitInRoom is a helper method for creating a new exit in the given room.
+func (c *Connection) NewExitInRoom(room IDType, name string, description string) *Exit {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return nil
+	}
 	r := c.FindRoomByID(room)
 	if r == nil || !c.CanEditRoom(r, "Exits") {
 		// Can't Destroy
 		return nil
 	}
+	if !c.checkQuota(map[ObjectType]int{ObjectExit: 1}) {
+		return nil
+	}
 	ack := make(chan *Exit)
-	c.Server.World.NewExit <- NewExitMessage{Room: room, Name: name, Owner: c.Player.ID, Ack: ack}
+	c.Server.World.NewExit <- NewExitMessage{Room: room, Name: name, Owner: c.Player.ID, Quota: c.Quota(c.Player, ObjectExit), Ack: ack}
 	ex := <-ack
 	if ex != nil {
 		ex.Description = description
+		c.Audit("create", ex.ID, "", "", ex.Name)
 	}
 	return ex
 }
 
+// FindExitByID is a helper method that returns an exit based on its ID.
+func (c *Connection) FindExitByID(id IDType) *Exit {
+	ack := make(chan []*Exit)
+	c.Server.World.FindExit <- FindExitMessage{ID: id, Ack: ack}
+	exits := <-ack
+	if len(exits) == 0 {
+		return nil
+	}
+	return exits[0]
+}
+
 // DestroyExit is a helper method that destroys an exit.
 func (c *Connection) DestroyExit(id IDType) *Exit {
 	if c == nil || !c.Authenticated || c.Player == nil || c.Player.Location.Type != LocationRoom {
@@ -634,8 +829,9 @@ func (c *Connection) DestroyExit(id IDType) *Exit {
 	}
 
 	var ex *Exit
-	for _, ex = range r.Exits {
-		if ex.ID == id {
+	for _, x := range r.Exits {
+		if x != nil && x.ID == id {
+			ex = x
 			break
 		}
 	}
@@ -645,8 +841,11 @@ func (c *Connection) DestroyExit(id IDType) *Exit {
 	}
 
 	ack := make(chan bool)
-	c.Server.World.DestroyExit <- DestroyExitMessage{Room: room, ID: id, Ack: ack}
-	<-ack
+	c.Server.World.DestroyExit <- DestroyExitMessage{Room: room, ID: id, By: c.Player.ID, Ack: ack}
+	if !<-ack {
+		return nil
+	}
+	c.Audit("destroy", id, "", ex.Name, "")
 
 	return ex
 }
@@ -656,10 +855,16 @@ func (c *Connection) NewItem(name string, description string) *Item {
 	if c == nil || !c.Authenticated || c.Player == nil {
 		return nil
 	}
+	if !c.checkQuota(map[ObjectType]int{ObjectItem: 1}) {
+		return nil
+	}
 	ack := make(chan *Item)
-	c.Server.World.NewItem <- NewItemMessage{Name: name, Owner: c.Player.ID, Ack: ack}
+	c.Server.World.NewItem <- NewItemMessage{Name: name, Owner: c.Player.ID, Quota: c.Quota(c.Player, ObjectItem), Ack: ack}
 	i := <-ack
-	i.Description = description
+	if i != nil {
+		i.Description = description
+		c.Audit("create", i.ID, "", "", i.Name)
+	}
 	return i
 }
 
@@ -713,110 +918,103 @@ func (c *Connection) DestroyItem(id IDType) *Item {
 	}
 
 	ack := make(chan bool)
-	c.Server.World.DestroyItem <- DestroyItemMessage{ID: id, Ack: ack}
-	<-ack
+	c.Server.World.DestroyItem <- DestroyItemMessage{ID: id, By: c.Player.ID, Ack: ack}
+	if !<-ack {
+		return nil
+	}
+	c.Audit("destroy", id, "", i.Name, "")
 
 	return i
 }
 
 // CanEditItem returns true of the player can edit the field on the object.
 func (c *Connection) CanEditItem(i *Item, field string) bool {
-	// TODO: This can be made more granular later.
-	if c == nil || !c.Authenticated || c.Player == nil || i == nil {
-		return false
-	}
-	if i.Owner != c.Player.ID && !c.IsAdmin() {
-		return false
-	}
-	return true
+	return i != nil && c.canEditObject(i, field)
 }
 
 // CanDestroyItem returns true of the player can destroy the item.
 func (c *Connection) CanDestroyItem(i *Item) bool {
-	// TODO: This can be made more granular later.
-	if c == nil || !c.Authenticated || c.Player == nil || i == nil {
-		return false
-	}
-	if i.Owner != c.Player.ID && !c.IsAdmin() {
-		return false
-	}
-	return true
+	return i != nil && !i.Flags.Has(FlagSafe) && c.canEditObject(i, "destroy")
 }
 
 // CanEditPlayer returns true if the player can edit the field on the room.
 func (c *Connection) CanEditPlayer(p *Player, field string) bool {
-	// TODO: This can be made more granular later.
-	if c == nil || !c.Authenticated || c.Player == nil || p == nil {
-		return false
+	return p != nil && c.canEditObject(p, field)
+}
+
+// DestroyPlayer is a helper method that destroys a player.
+// The things they owned are given to the heir, or destroyed if heir is nil.
+// Players who are online can't be destroyed.
+func (c *Connection) DestroyPlayer(id IDType, heir *Player) *Player {
+	if c == nil || !c.Authenticated || c.Player == nil || id == c.Player.ID {
+		return nil
 	}
-	if p.ID != c.Player.ID && !c.IsAdmin() {
-		return false
+	p := c.FindPlayerByID(id)
+	if !c.CanDestroyPlayer(p) {
+		// Can't Destroy
+		return nil
 	}
-	return true
+	for _, conn := range c.Server.PlayerConnections() {
+		if conn.Player != nil && conn.Player.ID == id {
+			return nil
+		}
+	}
+
+	m := DestroyPlayerMessage{ID: id, By: c.Player.ID, Fallback: c.Server.Config.FallbackRoom, Ack: make(chan *Cascade)}
+	if heir != nil {
+		m.Heir = heir.ID
+	}
+	c.Server.World.DestroyPlayer <- m
+	x := <-m.Ack
+	if x == nil {
+		return nil
+	}
+	c.Audit("destroy", id, "", p.Name, "")
+	for _, o := range x.Destroyed {
+		c.Audit("destroy", o.ObjectID(), "", o.ObjectName(), "")
+	}
+	for _, o := range x.Transferred {
+		c.Audit("set", o.ObjectID(), "owner", id.String(), o.ObjectOwner().String())
+	}
+	c.notifyCascade(p.Name, x)
+
+	return p
 }
 
 // CanDestroyPlayer returns true if the player can destroy the room.
 func (c *Connection) CanDestroyPlayer(p *Player) bool {
-	// TODO: This can be made more granular later.
-	if c == nil || !c.Authenticated || c.Player == nil || p == nil {
-		return false
-	}
-	if p.ID != c.Player.ID && !c.IsAdmin() {
-		return false
-	}
-	return true
+	return p != nil && !p.Flags.Has(FlagSafe) && c.canEditObject(p, "destroy")
 }
 
 // CanEditRoom returns true if the player can edit the field on the room.
+// Anybody can link to a room with FlagLinkOK.
 func (c *Connection) CanEditRoom(r *Room, field string) bool {
-	// TODO: This can be made more granular later.
-	if c == nil || !c.Authenticated || c.Player == nil || r == nil {
+	if r == nil {
 		return false
 	}
-	if r.Owner != c.Player.ID && !c.IsAdmin() {
-		return false
+	if field == "link" && r.Flags.Has(FlagLinkOK) && c.Role() >= RolePlayer {
+		return true
 	}
-	return true
+	return c.canEditObject(r, field)
 }
 
 // CanDestroyRoom returns true if the player can destroy the room.
 func (c *Connection) CanDestroyRoom(r *Room) bool {
-	// TODO: This can be made more granular later.
-	if c == nil || !c.Authenticated || c.Player == nil || r == nil {
-		return false
-	}
-	if r.Owner != c.Player.ID && !c.IsAdmin() {
-		return false
-	}
-	return true
+	return r != nil && !c.roomFlags(r).Has(FlagSafe) && c.canEditObject(r, "destroy")
 }
 
 // CanEditExit returns true if the player can edit the field on the room.
 func (c *Connection) CanEditExit(e *Exit, field string) bool {
-	// TODO: This can be made more granular later.
-	if c == nil || !c.Authenticated || c.Player == nil || e == nil {
-		return false
-	}
-	if e.Owner != c.Player.ID && !c.IsAdmin() {
-		return false
-	}
-	return true
+	return e != nil && c.canEditObject(e, field)
 }
 
 // CanDestroyExit returns true if the player can destroy the room.
 func (c *Connection) CanDestroyExit(e *Exit) bool {
-	// TODO: This can be made more granular later.
-	if c == nil || !c.Authenticated || c.Player == nil || e == nil {
-		return false
-	}
-	if e.Owner != c.Player.ID && !c.IsAdmin() {
-		return false
-	}
-	return true
+	return e != nil && !e.Flags.Has(FlagSafe) && c.canEditObject(e, "destroy")
 }
 
 // FindLocalThing is a helper method for finding an item, player, or exit in a given location.
-func (c *Connection) FindLocalThing(loc Location, nameOrID string, includeExits bool) (foundOne fmt.Stringer, foundMany []fmt.Stringer) {
+func (c *Connection) FindLocalThing(loc Location, nameOrID string, includeExits bool) (foundOne Object, foundMany []Object) {
 	if c.Player == nil {
 		return nil, nil
 	}
@@ -850,7 +1048,7 @@ func (c *Connection) FindLocalThing(loc Location, nameOrID string, includeExits
 			r := c.FindRoomByID(loc.ID)
 			if r != nil {
 				for _, e := range r.Exits {
-					if e.ID == id {
+					if e.ID == id && c.CanSeeExit(e) {
 						foundOne = e
 						break
 					}
@@ -859,8 +1057,8 @@ func (c *Connection) FindLocalThing(loc Location, nameOrID string, includeExits
 		}
 	} else {
 		// Look up by name
-		foundMany = make([]fmt.Stringer, 0)
-		things := make([]fmt.Stringer, 0)
+		foundMany = make([]Object, 0)
+		things := make([]Object, 0)
 		switch loc.Type {
 		case LocationItem:
 			i := c.FindItemByID(loc.ID)
@@ -878,7 +1076,9 @@ func (c *Connection) FindLocalThing(loc Location, nameOrID string, includeExits
 				things = append(things, r)
 				if includeExits {
 					for _, e := range r.Exits {
-						things = append(things, e)
+						if c.CanSeeExit(e) {
+							things = append(things, e)
+						}
 					}
 				}
 			}
@@ -928,6 +1128,24 @@ func (c *Connection) setPassword(id IDType, pw string) bool {
 	return <-ack
 }
 
+func (c *Connection) hasTOTP(id IDType) bool {
+	ack := make(chan bool)
+	c.Server.World.HasTOTP <- TOTPMessage{ID: id, Ack: ack}
+	return <-ack
+}
+
+func (c *Connection) checkTOTP(id IDType, code string) bool {
+	ack := make(chan bool)
+	c.Server.World.CheckTOTP <- TOTPMessage{ID: id, Code: code, Ack: ack}
+	return <-ack
+}
+
+func (c *Connection) setTOTP(id IDType, secret string, code string) bool {
+	ack := make(chan bool)
+	c.Server.World.SetTOTP <- TOTPMessage{ID: id, Secret: secret, Code: code, Ack: ack}
+	return <-ack
+}
+
 // ExecuteScriptWithScope executes the given code within the given scope.
 func (c *Connection) ExecuteScriptWithScope(scope map[string]interface{}, code string) error {
 	if c == nil || c.ScriptingEnv == nil {
@@ -0,0 +1,101 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+// testSession opens a logged in session for the player on the server.
+// Anything written to the session is thrown away.
+func testSession(s *Server, p *Player) *Connection {
+	server, client := net.Pipe()
+	go io.Copy(ioutil.Discard, client)
+	c := testConnection(s, p)
+	c.C = server
+	s.cm.addConnection(c)
+	return c
+}
+
+func TestSessions(t *testing.T) {
+	s := &Server{cm: NewConnectionManager(), Config: DefaultConfig()}
+	p := &Player{ID: 2, Name: "Pat"}
+	q := &Player{ID: 3, Name: "Quinn"}
+	a := testSession(s, p)
+	b := testSession(s, p)
+	c := testSession(s, q)
+	guest := testSession(s, nil)
+	guest.Authenticated = false
+
+	if sessions := s.cm.Sessions(p.ID); len(sessions) != 2 || sessions[0] != a || sessions[1] != b {
+		t.Errorf("Sessions() returned %d sessions for Pat, but we expected 2.", len(sessions))
+	}
+	if others := b.otherSessions(); len(others) != 1 || others[0] != a {
+		t.Errorf("otherSessions() returned %d sessions, but we expected Pat's first session.", len(others))
+	}
+	if conns := s.PlayerConnections(); len(conns) != 2 || conns[0] != a || conns[1] != c {
+		t.Errorf("PlayerConnections() returned %d connections, but we expected one each for Pat and Quinn.", len(conns))
+	}
+	s.cm.removeConnection(a)
+	if sessions := s.cm.Sessions(p.ID); len(sessions) != 1 || sessions[0] != b {
+		t.Errorf("Sessions() still returned a session after it was closed.")
+	}
+}
+
+func TestTakeOverSessions(t *testing.T) {
+	s := &Server{cm: NewConnectionManager(), Config: DefaultConfig()}
+	p := &Player{ID: 2, Name: "Pat"}
+
+	s.Config.SessionMode = SessionMulti
+	a := testSession(s, p)
+	if a.takeOverSessions() {
+		t.Errorf("takeOverSessions() said that Pat was already connected when they weren't.")
+	}
+	b := testSession(s, p)
+	if !b.takeOverSessions() {
+		t.Errorf("takeOverSessions() didn't notice Pat's first session.")
+	}
+	if _, err := a.C.Write([]byte("x")); err != nil {
+		t.Errorf("Logging in again closed the first session in multi mode: %v", err)
+	}
+	if targets := b.printTargets(); len(targets) != 2 || targets[0] != b || targets[1] != a {
+		t.Errorf("Text printed to the second session is written to %d sessions, but we expected both.", len(targets))
+	}
+
+	s.Config.SessionMode = SessionTakeover
+	if targets := b.printTargets(); len(targets) != 1 || targets[0] != b {
+		t.Errorf("Text printed to the second session is written to %d sessions in takeover mode, but we expected 1.", len(targets))
+	}
+	c := testSession(s, p)
+	if !c.takeOverSessions() {
+		t.Errorf("takeOverSessions() didn't notice Pat's other sessions.")
+	}
+	for _, old := range []*Connection{a, b} {
+		if _, err := old.C.Write([]byte("x")); err == nil {
+			t.Errorf("Logging in again didn't close an old session in takeover mode.")
+		}
+	}
+	if _, err := c.C.Write([]byte("x")); err != nil {
+		t.Errorf("Logging in again closed the new session: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+)
+
+// ObjectType is used to represent the type of an Object.
+type ObjectType uint8
+
+const (
+	// ObjectPlayer means that the object is a player.
+	ObjectPlayer ObjectType = iota
+	// ObjectRoom means that the object is a room.
+	ObjectRoom
+	// ObjectExit means that the object is an exit.
+	ObjectExit
+	// ObjectItem means that the object is an item.
+	ObjectItem
+)
+
+func (t ObjectType) String() string {
+	switch t {
+	case ObjectPlayer:
+		return "Player"
+	case ObjectRoom:
+		return "Room"
+	case ObjectExit:
+		return "Exit"
+	case ObjectItem:
+		return "Item"
+	}
+	return "Unknown"
+}
+
+// Object is implemented by everything in the world.
+// The command layer works with Objects, so adding a new kind of thing only requires implementing this interface.
+type Object interface {
+	fmt.Stringer
+	ObjectID() IDType
+	ObjectName() string
+	ObjectOwner() IDType
+	// ObjectLocation returns where the object is. Rooms aren't anywhere, so they return the zero Location.
+	ObjectLocation() Location
+	// ObjectAttributes returns the object's attribute values and information, creating the maps if needed.
+	ObjectAttributes() (map[string]string, map[string]AttributeInfo)
+	ObjectFlags() Flags
+	ObjectType() ObjectType
+
+	description() string
+	flags() *Flags
+	// editors, lock, and parent return nil if the object can't have editors, a lock, or a parent.
+	editors() *[]IDType
+	history() *[]Revision
+	lock() *Lock
+	parent() *IDType
+	canEdit(c *Connection, field string) bool
+	look(c *Connection) string
+	show(c *Connection) string
+	set(c *Connection, field string, value string)
+}
+
+// attributes creates the given attribute maps if they don't exist yet.
+func attributes(values *map[string]string, infos *map[string]AttributeInfo) (map[string]string, map[string]AttributeInfo) {
+	if *values == nil {
+		*values = make(map[string]string)
+	}
+	if *infos == nil {
+		*infos = make(map[string]AttributeInfo)
+	}
+	return *values, *infos
+}
+
+// ObjectID returns the player's ID.
+func (p *Player) ObjectID() IDType { return p.ID }
+
+// ObjectName returns the player's name.
+func (p *Player) ObjectName() string { return p.Name }
+
+// ObjectOwner returns the player's ID, since players own themselves.
+func (p *Player) ObjectOwner() IDType { return p.ID }
+
+// ObjectLocation returns the player's location.
+func (p *Player) ObjectLocation() Location { return p.Location }
+
+// ObjectAttributes returns the player's attributes.
+func (p *Player) ObjectAttributes() (map[string]string, map[string]AttributeInfo) {
+	return attributes(&p.Attributes, &p.AttributeInfo)
+}
+
+// ObjectFlags returns the player's flags.
+func (p *Player) ObjectFlags() Flags { return p.Flags }
+
+// ObjectType returns ObjectPlayer.
+func (p *Player) ObjectType() ObjectType { return ObjectPlayer }
+
+func (p *Player) flags() *Flags                                 { return &p.Flags }
+func (p *Player) description() string                           { return p.Description }
+func (p *Player) editors() *[]IDType                            { return nil }
+func (p *Player) history() *[]Revision                          { return &p.History }
+func (p *Player) lock() *Lock                                   { return nil }
+func (p *Player) parent() *IDType                               { return nil }
+func (p *Player) canEdit(c *Connection, field string) bool      { return c.CanEditPlayer(p, field) }
+func (p *Player) look(c *Connection) string                     { return c.lookPlayer(p) }
+func (p *Player) show(c *Connection) string                     { return c.showPlayer(p) }
+func (p *Player) set(c *Connection, field string, value string) { c.setPlayer(p, field, value) }
+
+// ObjectID returns the room's ID.
+func (r *Room) ObjectID() IDType { return r.ID }
+
+// ObjectName returns the room's name.
+func (r *Room) ObjectName() string { return r.Name }
+
+// ObjectOwner returns the ID of the player who owns the room.
+func (r *Room) ObjectOwner() IDType { return r.Owner }
+
+// ObjectLocation returns the zero Location, since rooms aren't inside anything.
+func (r *Room) ObjectLocation() Location { return Location{} }
+
+// ObjectAttributes returns the room's attributes.
+func (r *Room) ObjectAttributes() (map[string]string, map[string]AttributeInfo) {
+	return attributes(&r.Attributes, &r.AttributeInfo)
+}
+
+// ObjectFlags returns the room's flags.
+func (r *Room) ObjectFlags() Flags { return r.Flags }
+
+// ObjectType returns ObjectRoom.
+func (r *Room) ObjectType() ObjectType { return ObjectRoom }
+
+func (r *Room) flags() *Flags                                 { return &r.Flags }
+func (r *Room) description() string                           { return r.Description }
+func (r *Room) editors() *[]IDType                            { return &r.Editors }
+func (r *Room) history() *[]Revision                          { return &r.History }
+func (r *Room) lock() *Lock                                   { return &r.Lock }
+func (r *Room) parent() *IDType                               { return &r.Parent }
+func (r *Room) canEdit(c *Connection, field string) bool      { return c.CanEditRoom(r, field) }
+func (r *Room) look(c *Connection) string                     { return c.lookRoom(r) }
+func (r *Room) show(c *Connection) string                     { return c.showRoom(r) }
+func (r *Room) set(c *Connection, field string, value string) { c.setRoom(r, field, value) }
+
+// ObjectID returns the exit's ID.
+func (e *Exit) ObjectID() IDType { return e.ID }
+
+// ObjectName returns the exit's name.
+func (e *Exit) ObjectName() string { return e.Name }
+
+// ObjectOwner returns the ID of the player who owns the exit.
+func (e *Exit) ObjectOwner() IDType { return e.Owner }
+
+// ObjectLocation returns the room that the exit is in.
+func (e *Exit) ObjectLocation() Location { return Location{ID: e.Room, Type: LocationRoom} }
+
+// ObjectAttributes returns the exit's attributes.
+func (e *Exit) ObjectAttributes() (map[string]string, map[string]AttributeInfo) {
+	return attributes(&e.Attributes, &e.AttributeInfo)
+}
+
+// ObjectFlags returns the exit's flags.
+func (e *Exit) ObjectFlags() Flags { return e.Flags }
+
+// ObjectType returns ObjectExit.
+func (e *Exit) ObjectType() ObjectType { return ObjectExit }
+
+func (e *Exit) flags() *Flags                                 { return &e.Flags }
+func (e *Exit) description() string                           { return e.Description }
+func (e *Exit) editors() *[]IDType                            { return &e.Editors }
+func (e *Exit) history() *[]Revision                          { return &e.History }
+func (e *Exit) lock() *Lock                                   { return &e.Lock }
+func (e *Exit) parent() *IDType                               { return &e.Parent }
+func (e *Exit) canEdit(c *Connection, field string) bool      { return c.CanEditExit(e, field) }
+func (e *Exit) look(c *Connection) string                     { return c.lookExit(e) }
+func (e *Exit) show(c *Connection) string                     { return c.showExit(e) }
+func (e *Exit) set(c *Connection, field string, value string) { c.setExit(e, field, value) }
+
+// ObjectID returns the item's ID.
+func (i *Item) ObjectID() IDType { return i.ID }
+
+// ObjectName returns the item's name.
+func (i *Item) ObjectName() string { return i.Name }
+
+// ObjectOwner returns the ID of the player who owns the item.
+func (i *Item) ObjectOwner() IDType { return i.Owner }
+
+// ObjectLocation returns the item's location.
+func (i *Item) ObjectLocation() Location { return i.Location }
+
+// ObjectAttributes returns the item's attributes.
+func (i *Item) ObjectAttributes() (map[string]string, map[string]AttributeInfo) {
+	return attributes(&i.Attributes, &i.AttributeInfo)
+}
+
+// ObjectFlags returns the item's flags.
+func (i *Item) ObjectFlags() Flags { return i.Flags }
+
+// ObjectType returns ObjectItem.
+func (i *Item) ObjectType() ObjectType { return ObjectItem }
+
+func (i *Item) flags() *Flags                                 { return &i.Flags }
+func (i *Item) description() string                           { return i.Description }
+func (i *Item) editors() *[]IDType                            { return &i.Editors }
+func (i *Item) history() *[]Revision                          { return &i.History }
+func (i *Item) lock() *Lock                                   { return &i.Lock }
+func (i *Item) parent() *IDType                               { return &i.Parent }
+func (i *Item) canEdit(c *Connection, field string) bool      { return c.CanEditItem(i, field) }
+func (i *Item) look(c *Connection) string                     { return c.lookItem(i) }
+func (i *Item) show(c *Connection) string                     { return c.showItem(i) }
+func (i *Item) set(c *Connection, field string, value string) { c.setItem(i, field, value) }
+
+// FindObjectByID is a helper method that returns the player, room, exit, or item with the given ID.
+func (c *Connection) FindObjectByID(id IDType) Object {
+	if i := c.FindItemByID(id); i != nil {
+		return i
+	}
+	if r := c.FindRoomByID(id); r != nil {
+		return r
+	}
+	if e := c.FindExitByID(id); e != nil {
+		return e
+	}
+	if p := c.FindPlayerByID(id); p != nil {
+		return p
+	}
+	return nil
+}
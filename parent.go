@@ -0,0 +1,163 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parentOf returns the parent of a room, item, or exit, or nil if it doesn't have one.
+// The parent must be the same kind of thing.
+func (c *Connection) parentOf(t Object) Object {
+	id := t.parent()
+	if id == nil || *id == 0 {
+		return nil
+	}
+	p := c.FindObjectByID(*id)
+	if p == nil || p.ObjectType() != t.ObjectType() {
+		return nil
+	}
+	return p
+}
+
+// ancestors returns the parent chain of a room, item, or exit, starting with its parent.
+// The chain stops if it loops back on itself.
+func (c *Connection) ancestors(t Object) []Object {
+	r := make([]Object, 0)
+	seen := map[Object]bool{t: true}
+	for p := c.parentOf(t); p != nil && !seen[p]; p = c.parentOf(p) {
+		seen[p] = true
+		r = append(r, p)
+	}
+	return r
+}
+
+// inheritedAttribute looks up an attribute on a thing, falling back along its parent chain.
+// It returns the thing that the value came from.
+func (c *Connection) inheritedAttribute(t Object, name string) (value string, from Object, ok bool) {
+	values, _ := attributeMaps(t)
+	if v, ok := lookupAttribute(values, name); ok {
+		return v, t, true
+	}
+	for _, p := range c.ancestors(t) {
+		values, infos := attributeMaps(p)
+		if k, ok := attributeKey(values, name); ok && infos[k].Flags&AttributeNoInherit == 0 {
+			return values[k], p, true
+		}
+	}
+	return "", nil, false
+}
+
+// inheritedDescription returns the description of a thing.
+// If the thing doesn't have a description, the description of its closest ancestor is used.
+func (c *Connection) inheritedDescription(t Object) (string, Object) {
+	for _, x := range append([]Object{t}, c.ancestors(t)...) {
+		if d := x.description(); d != "" {
+			return d, x
+		}
+	}
+	return "", t
+}
+
+// inheritedLongDescription returns the long description of an exit, falling back along its parent chain.
+func (c *Connection) inheritedLongDescription(e *Exit) (string, Object) {
+	for _, x := range append([]Object{e}, c.ancestors(e)...) {
+		if v, ok := x.(*Exit); ok && v.LongDescription != "" {
+			return v.LongDescription, x
+		}
+	}
+	return "", e
+}
+
+// SetParent executes the "@parent <target>=<parent>" command.
+// An empty parent removes the target's parent.
+func (c *Connection) SetParent(target string, parent string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	var id IDType
+	if strings.TrimSpace(parent) != "" {
+		var err error
+		id, err = ParseID(parent)
+		if err != nil {
+			c.Printf("Parent must be an ID value of the form '@0'.\n")
+			return
+		}
+	}
+	c.setParent(t, id)
+}
+
+// setParent sets the parent of a thing. A parent ID of 0 removes the thing's parent.
+func (c *Connection) setParent(t Object, id IDType) {
+	if !c.canEdit(t, "parent") {
+		c.Printf("Can't set the parent of %s.\n", t)
+		return
+	}
+	field := t.parent()
+	if field == nil {
+		c.Printf("%s can't have a parent.\n", t)
+		return
+	}
+
+	var p Object
+	if id > 0 {
+		p = c.FindObjectByID(id)
+		if p == nil || p.ObjectType() != t.ObjectType() {
+			c.Printf("The parent must be the same kind of thing as %s.\n", t)
+			return
+		}
+		if !c.canEdit(p, "children") {
+			c.Printf("You don't have permission to use %s as a parent.\n", p)
+			return
+		}
+		if p == t {
+			c.Printf("%s can't be its own parent.\n", t)
+			return
+		}
+		for _, a := range c.ancestors(p) {
+			if a == t {
+				c.Printf("%s is already an ancestor of %s.\n", t, p)
+				return
+			}
+		}
+	}
+
+	c.changed(t, "parent", "parent", field.String(), id.String())
+	*field = id
+	if p == nil {
+		c.Printf("Cleared the parent of %s.\n", t)
+	} else {
+		c.Printf("%s is now the parent of %s.\n", p, t)
+	}
+}
+
+// showInherited formats a field value for the "show" command, noting where it was inherited from.
+func showInherited(name string, value string, from Object, t Object) string {
+	s := fmt.Sprintf("%15s : %q", name, value)
+	if from != t {
+		s += fmt.Sprintf(" (inherited from %s)", from)
+	}
+	return s + "\n"
+}
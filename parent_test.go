@@ -0,0 +1,116 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParents(t *testing.T) {
+	w := NewWorld()
+	p := &Player{ID: w.nextID(), Role: RoleBuilder, Location: Location{ID: w.db.DefaultRoom, Type: LocationRoom}}
+	w.db.Players[p.ID] = p
+	here := Location{ID: p.ID, Type: LocationPlayer}
+	chest := &Item{
+		ID:            w.nextID(),
+		Owner:         p.ID,
+		Location:      here,
+		Description:   "A wooden chest.",
+		Attributes:    map[string]string{"SOUND": "creak", "SECRET": "gold", "mood": "calm"},
+		AttributeInfo: map[string]AttributeInfo{"SECRET": {Flags: AttributeNoInherit}, "mood": {Flags: AttributeNoInherit}},
+	}
+	box := &Item{ID: w.nextID(), Owner: p.ID, Location: here, Attributes: map[string]string{"COLOR": "red", "SOUND": "thud"}}
+	crate := &Item{ID: w.nextID(), Owner: p.ID, Location: here}
+	for _, i := range []*Item{chest, box, crate} {
+		w.db.Items[i.ID] = i
+	}
+	c := testConnection(testServer(t, w), p)
+
+	c.SetParent(box.ID.String(), chest.ID.String())
+	c.SetParent(crate.ID.String(), box.ID.String())
+	if box.Parent != chest.ID || crate.Parent != box.ID {
+		t.Fatalf("setParent() didn't set up the chain chest <- box <- crate.")
+	}
+	if a := c.ancestors(crate); len(a) != 2 || a[0] != box || a[1] != chest {
+		t.Errorf("ancestors() = %v, but we expected box, chest.", a)
+	}
+	if d, from := c.inheritedDescription(crate); d != chest.Description || from != chest {
+		t.Errorf("inheritedDescription() = %q from %s, but we expected the chest's description.", d, from)
+	}
+	for _, x := range []struct {
+		name  string
+		value string
+		from  fmt.Stringer
+		ok    bool
+	}{
+		{"color", "red", box, true},
+		{"SOUND", "thud", box, true},
+		{"SECRET", "", nil, false},
+		{"Mood", "", nil, false},
+	} {
+		v, from, ok := c.inheritedAttribute(crate, x.name)
+		if v != x.value || from != x.from || ok != x.ok {
+			t.Errorf("inheritedAttribute(%q) = %q, %v, %v, but we expected %q, %v, %v.", x.name, v, from, ok, x.value, x.from, x.ok)
+		}
+	}
+
+	c.SetParent(chest.ID.String(), crate.ID.String())
+	if chest.Parent != 0 {
+		t.Errorf("setParent() created a cycle through the chest's descendants.")
+	}
+	c.SetParent(chest.ID.String(), chest.ID.String())
+	if chest.Parent != 0 {
+		t.Errorf("setParent() made the chest its own parent.")
+	}
+	c.SetParent(crate.ID.String(), "")
+	if crate.Parent != 0 || len(c.ancestors(crate)) != 0 {
+		t.Errorf("setParent() didn't clear the crate's parent.")
+	}
+}
+
+func TestInheritedAttributeVisibility(t *testing.T) {
+	w := NewWorld()
+	owner := &Player{ID: w.nextID(), Role: RoleBuilder}
+	p := &Player{ID: w.nextID()}
+	w.db.Players[owner.ID] = owner
+	w.db.Players[p.ID] = p
+	here := Location{ID: w.db.DefaultRoom, Type: LocationRoom}
+	box := &Item{
+		ID:            w.nextID(),
+		Owner:         owner.ID,
+		Location:      here,
+		Attributes:    map[string]string{"SOUND": "thud"},
+		AttributeInfo: map[string]AttributeInfo{"SOUND": {Flags: AttributeVisible}},
+	}
+	crate := &Item{ID: w.nextID(), Owner: owner.ID, Location: here, Parent: box.ID, Attributes: map[string]string{"SOUND": "rattle"}}
+	w.db.Items[box.ID] = box
+	w.db.Items[crate.ID] = crate
+	s := testServer(t, w)
+
+	// The crate's own sound can't be read by other players, so they shouldn't see the box's sound in its place.
+	if a := testConnection(s, p).showAttributes(crate); strings.Contains(a, "thud") || strings.Contains(a, "rattle") {
+		t.Errorf("showAttributes() showed another player a sound that they shouldn't see:\n%s", a)
+	}
+	if a := testConnection(s, owner).showAttributes(crate); !strings.Contains(a, "rattle") || strings.Contains(a, "thud") {
+		t.Errorf("showAttributes() didn't show the owner the crate's own sound:\n%s", a)
+	}
+}
@@ -0,0 +1,204 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// quotaTypes are the kinds of things that building quotas apply to.
+var quotaTypes = []ObjectType{ObjectRoom, ObjectItem, ObjectExit}
+
+// UsageMessage is sent to Usage to count the rooms, items, and exits that a player owns.
+type UsageMessage struct {
+	Owner IDType
+	Ack   chan map[ObjectType]int
+}
+
+// usage counts the rooms, items, and exits that a player owns.
+// Since only things that still exist are counted, destroying something refunds its quota.
+// It must only be called from WorldThread.
+func (w *World) usage(owner IDType) map[ObjectType]int {
+	r := make(map[ObjectType]int)
+	for _, room := range w.db.Rooms {
+		if room.Owner == owner {
+			r[ObjectRoom]++
+		}
+		for _, e := range room.Exits {
+			if e != nil && e.Owner == owner {
+				r[ObjectExit]++
+			}
+		}
+	}
+	for _, i := range w.db.Items {
+		if i.Owner == owner {
+			r[ObjectItem]++
+		}
+	}
+	return r
+}
+
+// withinQuota returns true if the owner can have the given number of new things of each type.
+// A missing or negative limit means that there is no limit.
+// It must only be called from WorldThread.
+func (w *World) withinQuota(owner IDType, quota map[ObjectType]int, need map[ObjectType]int) bool {
+	used := w.usage(owner)
+	for t, n := range need {
+		if q, ok := quota[t]; ok && q >= 0 && used[t]+n > q {
+			return false
+		}
+	}
+	return true
+}
+
+// Quota returns the most things of the given type that the player can own.
+// A negative value means that there is no limit. Wizards don't have quotas.
+func (c *Connection) Quota(p *Player, t ObjectType) int {
+	if p == nil || p.EffectiveRole() >= RoleWizard {
+		return -1
+	}
+	if q, ok := p.Quota[t]; ok {
+		return q
+	}
+	switch t {
+	case ObjectRoom:
+		return c.Server.Config.RoomQuota
+	case ObjectItem:
+		return c.Server.Config.ItemQuota
+	case ObjectExit:
+		return c.Server.Config.ExitQuota
+	}
+	return -1
+}
+
+// quotas returns all of the player's quotas.
+func (c *Connection) quotas(p *Player) map[ObjectType]int {
+	r := make(map[ObjectType]int)
+	for _, t := range quotaTypes {
+		r[t] = c.Quota(p, t)
+	}
+	return r
+}
+
+// Usage is a helper method that counts the rooms, items, and exits that a player owns.
+func (c *Connection) Usage(id IDType) map[ObjectType]int {
+	ack := make(chan map[ObjectType]int)
+	c.Server.World.Usage <- UsageMessage{Owner: id, Ack: ack}
+	return <-ack
+}
+
+// checkQuota returns true if the player can create the given number of new things of each type.
+// Otherwise it tells the player which quota they have reached.
+func (c *Connection) checkQuota(need map[ObjectType]int) bool {
+	used := c.Usage(c.Player.ID)
+	for _, t := range quotaTypes {
+		q := c.Quota(c.Player, t)
+		if q >= 0 && used[t]+need[t] > q {
+			c.Printf("You have reached your %s quota of %d.\n", strings.ToLower(t.String()), q)
+			return false
+		}
+	}
+	return true
+}
+
+// parseQuotaType parses the kind of thing that a quota is for.
+func parseQuotaType(s string) (ObjectType, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(strings.ToLower(s)), "s")
+	for _, t := range quotaTypes {
+		if strings.ToLower(t.String()) == s {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("quotas can only be set for rooms, items, and exits")
+}
+
+// ShowQuota executes the "@quota [<player>]" command.
+// Only admins can see other players' quotas.
+func (c *Connection) ShowQuota(target string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	p := c.Player
+	if strings.TrimSpace(target) != "" {
+		p = c.lookupPlayer(target)
+		if p == nil {
+			c.Printf("%s is not a player.\n", target)
+			return
+		}
+		if p.ID != c.Player.ID && !c.IsAdmin() {
+			c.Printf("You can only see your own quota.\n")
+			return
+		}
+	}
+	used := c.Usage(p.ID)
+	s := fmt.Sprintf("Quota for %s:\n", p)
+	for _, t := range quotaTypes {
+		limit := "unlimited"
+		if q := c.Quota(p, t); q >= 0 {
+			limit = strconv.Itoa(q)
+		}
+		s += fmt.Sprintf("%10s : %d of %s\n", t.String()+"s", used[t], limit)
+	}
+	c.Printf("%s\n", s)
+}
+
+// SetQuota executes the "@quota <player> <rooms|items|exits>=<limit>" command.
+// A limit of "default" removes the player's own limit, and a negative limit means that there is no limit.
+func (c *Connection) SetQuota(target string, kind string, limit string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	if !c.IsAdmin() {
+		c.Printf("Only admins can change quotas.\n")
+		return
+	}
+	p := c.lookupPlayer(target)
+	if p == nil {
+		c.Printf("%s is not a player.\n", target)
+		return
+	}
+	t, err := parseQuotaType(kind)
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	limit = strings.TrimSpace(strings.ToLower(limit))
+	field := strings.ToLower(t.String())
+	old := strconv.Itoa(c.Quota(p, t))
+	if limit == "default" {
+		delete(p.Quota, t)
+		c.Audit("quota", p.ID, field, old, strconv.Itoa(c.Quota(p, t)))
+		c.Printf("%s now has the default %s quota.\n", p, strings.ToLower(t.String()))
+		return
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil {
+		c.Printf("The limit must be a number or 'default'.\n")
+		return
+	}
+	if p.Quota == nil {
+		p.Quota = make(map[ObjectType]int)
+	}
+	p.Quota[t] = n
+	c.Audit("quota", p.ID, field, old, limit)
+	c.Printf("Set.\n")
+}
@@ -0,0 +1,53 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+func TestQuota(t *testing.T) {
+	w := NewWorld()
+	owner := w.nextID()
+	r := &Room{ID: w.nextID(), Owner: owner}
+	r.Exits = append(r.Exits, &Exit{ID: w.nextID(), Owner: owner, Room: r.ID})
+	w.db.Rooms[r.ID] = r
+	box := &Item{ID: w.nextID(), Owner: owner, Location: Location{ID: r.ID, Type: LocationRoom}}
+	w.db.Items[box.ID] = box
+
+	used := w.usage(owner)
+	if used[ObjectRoom] != 1 || used[ObjectExit] != 1 || used[ObjectItem] != 1 {
+		t.Errorf("usage(%s) = %v, but we expected one of each.", owner, used)
+	}
+	quota := map[ObjectType]int{ObjectRoom: 2, ObjectExit: 1, ObjectItem: -1}
+	if !w.withinQuota(owner, quota, map[ObjectType]int{ObjectRoom: 1, ObjectItem: 100}) {
+		t.Errorf("withinQuota() = false, but the owner has room for another room and no item limit.")
+	}
+	if w.withinQuota(owner, quota, map[ObjectType]int{ObjectExit: 1}) {
+		t.Errorf("withinQuota() = true, but the owner is already at their exit quota.")
+	}
+	cost := w.cloneCost(CloneMessage{ID: r.ID, Deep: true})
+	if cost[ObjectRoom] != 1 || cost[ObjectExit] != 1 || cost[ObjectItem] != 1 {
+		t.Errorf("cloneCost() = %v, but we expected one of each.", cost)
+	}
+	if w.clone(CloneMessage{ID: r.ID, Owner: owner, Deep: true, Quota: quota}) != nil {
+		t.Errorf("clone() ignored the owner's exit quota.")
+	}
+}
@@ -0,0 +1,301 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role determines what a player is allowed to do.
+// Each role can do everything that the roles below it can do.
+type Role uint8
+
+const (
+	// RoleGuest can look around and talk, but can't build.
+	RoleGuest Role = iota + 1
+	// RolePlayer can build and edit the things they own.
+	RolePlayer
+	// RoleBuilder can also clone, reparent, give away, and share the things they own.
+	RoleBuilder
+	// RoleStaff can also edit any room, item, or exit and summon things.
+	RoleStaff
+	// RoleWizard can do anything to anybody with a lower role.
+	RoleWizard
+	// RoleGod can do anything to anybody.
+	RoleGod
+)
+
+var roleNames = map[Role]string{
+	RoleGuest:   "guest",
+	RolePlayer:  "player",
+	RoleBuilder: "builder",
+	RoleStaff:   "staff",
+	RoleWizard:  "wizard",
+	RoleGod:     "god",
+}
+
+func (r Role) String() string {
+	n, ok := roleNames[r]
+	if !ok {
+		return "unknown"
+	}
+	return n
+}
+
+// ParseRole parses the name of a role.
+func ParseRole(s string) (Role, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	for r, n := range roleNames {
+		if n == s {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role: %s", s)
+}
+
+// commandPolicy holds the lowest role that can use each command.
+// Commands that aren't listed can be used by everybody.
+var commandPolicy = map[string]Role{
+	"create":     RolePlayer,
+	"@dig":       RolePlayer,
+	"destroy":    RolePlayer,
+	"set":        RolePlayer,
+	"@set":       RolePlayer,
+	"@lock":      RolePlayer,
+	"@unlock":    RolePlayer,
+	"@wipe":      RolePlayer,
+	"@aflag":     RolePlayer,
+	"@atype":     RolePlayer,
+	"@revert":    RolePlayer,
+	"@undestroy": RolePlayer,
+	"@link":      RolePlayer,
+	"@tel":       RolePlayer,
+	"@zone":      RolePlayer,
+	"@clone":     RoleBuilder,
+	"@parent":    RoleBuilder,
+	"@grant":     RoleBuilder,
+	"@revoke":    RoleBuilder,
+	"summon":     RoleStaff,
+	"save":       RoleStaff,
+	"shutdown":   RoleWizard,
+	"exec":       RoleWizard,
+	"@role":      RoleWizard,
+	"@audit":     RoleWizard,
+	"@dbck":      RoleWizard,
+	"@map":       RoleWizard,
+
+	"test-scripting": RoleWizard,
+}
+
+// fieldPolicy holds the lowest role that can change each field on a thing they are allowed to edit.
+// Fields that aren't listed need RolePlayer.
+var fieldPolicy = map[string]Role{
+	"owner":    RoleBuilder,
+	"parent":   RoleBuilder,
+	"children": RoleBuilder,
+	"clone":    RoleBuilder,
+	"editors":  RoleBuilder,
+}
+
+// fieldRole returns the lowest role that can change the given field.
+func fieldRole(field string) Role {
+	r, ok := fieldPolicy[strings.ToLower(field)]
+	if !ok {
+		return RolePlayer
+	}
+	return r
+}
+
+// EffectiveRole returns the player's role.
+// Players who haven't been given a role are treated as RolePlayer,
+// and FlagWizard and FlagBuilder raise the player's role to at least RoleWizard and RoleBuilder.
+func (p *Player) EffectiveRole() Role {
+	r := p.Role
+	if r == 0 {
+		r = RolePlayer
+	}
+	if p.Flags.Has(FlagWizard) && r < RoleWizard {
+		r = RoleWizard
+	}
+	if p.Flags.Has(FlagBuilder) && r < RoleBuilder {
+		r = RoleBuilder
+	}
+	return r
+}
+
+// flagRole returns the highest role that the given flags raise a player to, or 0 if they don't raise it at all.
+func flagRole(f Flags) Role {
+	switch {
+	case f.Has(FlagWizard):
+		return RoleWizard
+	case f.Has(FlagBuilder):
+		return RoleBuilder
+	}
+	return 0
+}
+
+// canGiveRole returns true if the player can give the role to somebody else.
+// Only gods can give out a role at or above their own.
+func (c *Connection) canGiveRole(r Role) bool {
+	return r < c.Role() || c.Role() == RoleGod
+}
+
+// Role returns the player's effective role, or 0 if they haven't logged in.
+func (c *Connection) Role() Role {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return 0
+	}
+	return c.Player.EffectiveRole()
+}
+
+// CanUseCommand returns true if the player's role allows them to use the given command.
+func (c *Connection) CanUseCommand(name string) bool {
+	r, ok := commandPolicy[name]
+	if !ok {
+		r = RoleGuest
+	}
+	return c.Role() >= r
+}
+
+// isEditor returns true if the player has been granted permission to edit the thing.
+// Editors of a room can also edit the exits in it, and the builders of a zone can edit the rooms in it.
+func (c *Connection) isEditor(t Object) bool {
+	if ids := t.editors(); ids != nil {
+		for _, id := range *ids {
+			if id == c.Player.ID {
+				return true
+			}
+		}
+	}
+	if e, ok := t.(*Exit); ok {
+		if r := c.FindRoomByID(e.Room); r != nil {
+			return c.isEditor(r)
+		}
+	}
+	if r, ok := t.(*Room); ok {
+		return c.isZoneBuilder(c.zoneOf(r))
+	}
+	return false
+}
+
+// canEditObject returns true if the player can change the field on the thing.
+// Players can edit things they own or have been granted, as long as their role allows them to change the field.
+// Nobody can edit a player with the same or a higher role, except that player.
+func (c *Connection) canEditObject(t Object, field string) bool {
+	if c == nil || !c.Authenticated || c.Player == nil || t == nil {
+		return false
+	}
+	role := c.Role()
+	if role < fieldRole(field) {
+		return false
+	}
+	if t.ObjectType() == ObjectPlayer && t.ObjectID() != c.Player.ID {
+		p, ok := t.(*Player)
+		if !ok || p.EffectiveRole() >= role {
+			return false
+		}
+	}
+	switch {
+	case role >= RoleWizard:
+		return true
+	case t.ObjectOwner() == c.Player.ID || c.isEditor(t):
+		return true
+	case role >= RoleStaff:
+		return t.ObjectType() != ObjectPlayer
+	}
+	return false
+}
+
+// lookupPlayer finds a player by name or ID.
+func (c *Connection) lookupPlayer(s string) *Player {
+	s = strings.TrimSpace(s)
+	if id, err := ParseID(s); err == nil {
+		return c.FindPlayerByID(id)
+	}
+	return c.FindPlayerByName(s)
+}
+
+// SetRole executes the "@role <player>=<role>" command.
+// Only gods can give out a role at or above their own.
+func (c *Connection) SetRole(target string, role string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	p := c.lookupPlayer(target)
+	if p == nil {
+		c.Printf("%s is not a player.\n", target)
+		return
+	}
+	r, err := ParseRole(role)
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if !c.canEditObject(p, "role") || !c.canGiveRole(r) {
+		c.Printf("You can't make %s a %s.\n", p, r)
+		return
+	}
+	c.Audit("role", p.ID, "role", p.EffectiveRole().String(), r.String())
+	p.Role = r
+	// The wizard and builder flags would otherwise keep raising the player above the new role.
+	if old := p.Flags; old&(FlagWizard|FlagBuilder) != 0 {
+		p.Flags = setFlag(old, FlagWizard|FlagBuilder, false)
+		c.changed(p, "flag", "flags", old.String(), p.Flags.String())
+	}
+	c.Printf("%s is now a %s.\n", p, r)
+}
+
+// Grant executes the "@grant" and "@revoke" commands, which share editing of a room, item, or exit with another player.
+func (c *Connection) Grant(target string, player string, grant bool) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	t := c.findTarget(target)
+	if t == nil {
+		return
+	}
+	ids := t.editors()
+	if ids == nil || !c.canEdit(t, "editors") || (t.ObjectOwner() != c.Player.ID && c.Role() < RoleWizard) {
+		c.Printf("You can't share %s.\n", t)
+		return
+	}
+	p := c.lookupPlayer(player)
+	if p == nil {
+		c.Printf("%s is not a player.\n", player)
+		return
+	}
+	r := make([]IDType, 0, len(*ids))
+	for _, id := range *ids {
+		if id != p.ID {
+			r = append(r, id)
+		}
+	}
+	action := "revoke"
+	if grant {
+		action = "grant"
+		r = append(r, p.ID)
+		c.Printf("%s can now edit %s.\n", p, t)
+	} else {
+		c.Printf("%s can no longer edit %s.\n", p, t)
+	}
+	c.Audit(action, t.ObjectID(), "editors", showIDs(*ids), showIDs(r))
+	*ids = r
+}
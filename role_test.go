@@ -0,0 +1,107 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+func TestEffectiveRole(t *testing.T) {
+	tests := []struct {
+		p Player
+		r Role
+	}{
+		{Player{}, RolePlayer},
+		{Player{Role: RoleGuest}, RoleGuest},
+		{Player{Role: RoleGuest, Flags: FlagBuilder}, RoleBuilder},
+		{Player{Role: RoleStaff, Flags: FlagBuilder}, RoleStaff},
+		{Player{Flags: FlagWizard}, RoleWizard},
+		{Player{Role: RoleGod, Flags: FlagWizard}, RoleGod},
+	}
+	for _, x := range tests {
+		if r := x.p.EffectiveRole(); r != x.r {
+			t.Errorf("EffectiveRole() of %+v = %s, but we expected %s.", x.p, r, x.r)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for r, n := range roleNames {
+		p, err := ParseRole(" " + n + " ")
+		if err != nil || p != r {
+			t.Errorf("ParseRole(%q) = %s, %v, but we expected %s.", n, p, err, r)
+		}
+	}
+	if _, err := ParseRole("emperor"); err == nil {
+		t.Errorf("ParseRole(%q) didn't throw an error.", "emperor")
+	}
+}
+
+func TestCanSetFlags(t *testing.T) {
+	wizard := &Player{ID: 2, Role: RoleWizard}
+	god := &Player{ID: 3, Role: RoleGod}
+	p := &Player{ID: 4, Role: RolePlayer}
+	tests := []struct {
+		by  *Player
+		old Flags
+		new Flags
+		ok  bool
+	}{
+		{wizard, 0, FlagBuilder, true},
+		{wizard, 0, FlagWizard, false},
+		{wizard, 0, FlagWizard | FlagBuilder, false},
+		{wizard, FlagBuilder, 0, true},
+		{wizard, 0, FlagDark, true},
+		{god, 0, FlagWizard, true},
+		{p, 0, FlagBuilder, false},
+	}
+	for _, x := range tests {
+		c := &Connection{Player: x.by, Authenticated: true}
+		if ok := c.canSetFlags(p, x.old, x.new); ok != x.ok {
+			t.Errorf("canSetFlags() by a %s from %q to %q = %v, but we expected %v.", x.by.Role, x.old, x.new, ok, x.ok)
+		}
+	}
+}
+
+func TestSetRoleClearsFlags(t *testing.T) {
+	w := NewWorld()
+	god := &Player{ID: w.nextID(), Name: "God", Role: RoleGod}
+	p := &Player{ID: w.nextID(), Name: "Merlin", Flags: FlagWizard | FlagBuilder | FlagDark}
+	w.db.Players[god.ID] = god
+	w.db.Players[p.ID] = p
+	s := testServer(t, w)
+
+	testConnection(s, god).SetRole(p.ID.String(), "player")
+	if r := p.EffectiveRole(); r != RolePlayer {
+		t.Errorf("After demoting a flagged wizard, EffectiveRole() = %s, but we expected %s.", r, RolePlayer)
+	}
+	if p.Flags != FlagDark {
+		t.Errorf("After demoting a flagged wizard, Flags = %q, but we expected only %q.", p.Flags, FlagDark)
+	}
+	c := testConnection(s, p)
+	if c.evalLock("flag:wizard") || c.evalLock("flag:builder") {
+		t.Errorf("A demoted player still passes role flag locks.")
+	}
+
+	p.Role = RoleWizard
+	if !c.evalLock("flag:wizard") || !c.evalLock("flag:admin") || !c.evalLock("flag:builder") {
+		t.Errorf("A wizard without the wizard flag doesn't pass role flag locks.")
+	}
+}
@@ -0,0 +1,160 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTPPeriod is the length of time that a TOTP code is valid for.
+const TOTPPeriod = 30 * time.Second
+
+// TOTPDigits is the number of digits in a TOTP code.
+const TOTPDigits = 6
+
+// TOTPSkew is the number of periods before and after the current one that will still be accepted.
+const TOTPSkew = 1
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret generates a new random TOTP secret encoded in base32.
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpStep returns the number of TOTP periods between the Unix epoch and the given time.
+func totpStep(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(TOTPPeriod/time.Second)
+}
+
+// TOTPCode returns the TOTP code for the given secret at the given time, as described in RFC 6238.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, totpStep(t), TOTPDigits), nil
+}
+
+// MatchTOTP returns the time step that the code belongs to, or false if the code isn't valid for the given secret at the given time.
+func MatchTOTP(secret string, code string, t time.Time) (uint64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	for i := -TOTPSkew; i <= TOTPSkew; i++ {
+		at := t.Add(time.Duration(i) * TOTPPeriod)
+		expected, err := TOTPCode(secret, at)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return totpStep(at), true
+		}
+	}
+	return 0, false
+}
+
+// CheckTOTP returns true if the code is valid for the given secret at the given time.
+func CheckTOTP(secret string, code string, t time.Time) bool {
+	_, ok := MatchTOTP(secret, code, t)
+	return ok
+}
+
+// checkTOTP returns true if the code is valid for the player's TOTP secret at the given time.
+// Each time step can only be used once, as RFC 6238 section 5.2 requires, so a code can't be replayed.
+// It must only be called from WorldThread.
+func (w *World) checkTOTP(id IDType, code string, t time.Time) bool {
+	secret, ok := w.db.TOTP[id]
+	p := w.db.Players[id]
+	if !ok || p == nil {
+		return false
+	}
+	step, ok := MatchTOTP(secret, code, t)
+	if !ok || step <= p.LastTOTPStep {
+		return false
+	}
+	p.LastTOTPStep = step
+	return true
+}
+
+// setTOTP sets or removes a player's TOTP secret.
+// The code that the player confirmed a new secret with is used up, so it can't be used to log in.
+// It must only be called from WorldThread.
+func (w *World) setTOTP(e TOTPMessage, t time.Time) {
+	if e.Secret == "" {
+		delete(w.db.TOTP, e.ID)
+		return
+	}
+	w.db.TOTP[e.ID] = e.Secret
+	if p := w.db.Players[e.ID]; p != nil {
+		p.LastTOTPStep, _ = MatchTOTP(e.Secret, e.Code, t)
+	}
+}
+
+// TOTPURI returns an otpauth URI that authenticator apps can use to enroll the given secret.
+func TOTPURI(issuer string, account string, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	v.Set("period", fmt.Sprintf("%d", int(TOTPPeriod/time.Second)))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, v.Encode())
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.Replace(strings.TrimSpace(secret), " ", "", -1))
+	s = strings.TrimRight(s, "=")
+	key, err := totpEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %s", err.Error())
+	}
+	return key, nil
+}
+
+// hotp computes an HOTP value as described in RFC 4226.
+func hotp(key []byte, counter uint64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, v%mod)
+}
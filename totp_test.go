@@ -0,0 +1,90 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+type testPairTOTP struct {
+	t    int64
+	code string
+}
+
+// These come from the SHA1 test vectors in RFC 6238, truncated to six digits.
+var totpTests = []testPairTOTP{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+}
+
+// TestTOTP tests TOTP code generation and validation.
+func TestTOTP(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	for _, x := range totpTests {
+		tm := time.Unix(x.t, 0)
+		code, err := TOTPCode(secret, tm)
+		if err != nil {
+			t.Errorf("TOTPCode(%d) threw an error: %s", x.t, err.Error())
+		} else if code != x.code {
+			t.Errorf("TOTPCode(%d) = %s, but we expected %s.", x.t, code, x.code)
+		}
+		if !CheckTOTP(secret, x.code, tm.Add(TOTPPeriod)) {
+			t.Errorf("CheckTOTP(%d) rejected a code from the previous period.", x.t)
+		}
+		if CheckTOTP(secret, x.code, tm.Add(5*TOTPPeriod)) {
+			t.Errorf("CheckTOTP(%d) accepted an expired code.", x.t)
+		}
+	}
+}
+
+func TestTOTPReplay(t *testing.T) {
+	w := NewWorld()
+	p := &Player{ID: w.nextID()}
+	w.db.Players[p.ID] = p
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+	enroll, _ := TOTPCode(secret, now)
+	w.setTOTP(TOTPMessage{ID: p.ID, Secret: secret, Code: enroll}, now)
+	if w.checkTOTP(p.ID, enroll, now) {
+		t.Errorf("checkTOTP() accepted the code that was used to enroll.")
+	}
+
+	later := now.Add(2 * TOTPPeriod)
+	code, _ := TOTPCode(secret, later)
+	if !w.checkTOTP(p.ID, code, later) {
+		t.Fatalf("checkTOTP() rejected a valid code.")
+	}
+	if w.checkTOTP(p.ID, code, later.Add(TOTPPeriod)) {
+		t.Errorf("checkTOTP() accepted the same code twice.")
+	}
+	previous, _ := TOTPCode(secret, later.Add(-TOTPPeriod))
+	if w.checkTOTP(p.ID, previous, later) {
+		t.Errorf("checkTOTP() accepted a code from before the last one that was used.")
+	}
+	next, _ := TOTPCode(secret, later.Add(TOTPPeriod))
+	if !w.checkTOTP(p.ID, next, later.Add(TOTPPeriod)) {
+		t.Errorf("checkTOTP() rejected the code from the next period.")
+	}
+}
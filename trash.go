@@ -0,0 +1,291 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PurgeTrashFrequency represents how often destroyed objects are checked to see if they should be purged.
+const PurgeTrashFrequency time.Duration = 10 * time.Minute
+
+// Trash holds a destroyed room, exit, or item until it is restored or purged.
+// A room keeps its exits with it.
+type Trash struct {
+	Destroyed   time.Time
+	DestroyedBy IDType
+	Room        *Room
+	Exit        *Exit
+	Item        *Item
+}
+
+// Object returns the destroyed object.
+func (t *Trash) Object() Object {
+	switch {
+	case t.Room != nil:
+		return t.Room
+	case t.Exit != nil:
+		return t.Exit
+	case t.Item != nil:
+		return t.Item
+	}
+	return nil
+}
+
+func (t *Trash) String() string {
+	o := t.Object()
+	if o == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s %s, destroyed %s by %s", o.ObjectType(), o, t.Destroyed.Format(time.RFC3339), t.DestroyedBy)
+}
+
+// FindTrashMessage is sent to FindTrash to list destroyed objects.
+// If ID is set, only that object is returned. If Owner is set, only objects with that owner are returned.
+type FindTrashMessage struct {
+	ID    IDType
+	Owner IDType
+	Ack   chan []*Trash
+}
+
+// UndestroyMessage is sent to Undestroy to restore a destroyed object.
+// Items whose location no longer exists are given to Player.
+// Nothing is restored if it would put the object's owner over their Quota.
+type UndestroyMessage struct {
+	ID     IDType
+	Player IDType
+	Quota  map[ObjectType]int
+	Ack    chan error
+}
+
+// trash moves an object into the trash.
+// It must only be called from WorldThread.
+func (w *World) trash(t *Trash) {
+	o := t.Object()
+	if o == nil {
+		return
+	}
+	if t.Destroyed.IsZero() {
+		t.Destroyed = time.Now()
+	}
+	w.db.Trash[o.ObjectID()] = t
+}
+
+// findTrash returns destroyed objects, oldest first.
+// It must only be called from WorldThread.
+func (w *World) findTrash(e FindTrashMessage) []*Trash {
+	r := make([]*Trash, 0)
+	for id, t := range w.db.Trash {
+		o := t.Object()
+		if o == nil || (e.ID > 0 && id != e.ID) || (e.Owner > 0 && o.ObjectOwner() != e.Owner) {
+			continue
+		}
+		r = append(r, t)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Destroyed.Before(r[j].Destroyed) })
+	return r
+}
+
+// undestroy puts a destroyed object back into the world.
+// It must only be called from WorldThread.
+func (w *World) undestroy(e UndestroyMessage) error {
+	t, ok := w.db.Trash[e.ID]
+	if !ok || t.Object() == nil {
+		return fmt.Errorf("%s isn't in the trash", e.ID)
+	}
+	need := make(map[ObjectType]int)
+	switch {
+	case t.Room != nil:
+		need[ObjectRoom]++
+		for _, x := range t.Room.Exits {
+			if x != nil && x.Owner == t.Room.Owner {
+				need[ObjectExit]++
+			}
+		}
+	case t.Exit != nil:
+		need[ObjectExit]++
+	case t.Item != nil:
+		need[ObjectItem]++
+	}
+	if !w.withinQuota(t.Object().ObjectOwner(), e.Quota, need) {
+		return fmt.Errorf("restoring it would put its owner over their quota")
+	}
+	switch {
+	case t.Room != nil:
+		w.db.Rooms[t.Room.ID] = t.Room
+	case t.Exit != nil:
+		r := w.db.Rooms[t.Exit.Room]
+		if r == nil {
+			return fmt.Errorf("the room that it was in, %s, doesn't exist", t.Exit.Room)
+		}
+		r.Exits = append(r.Exits, t.Exit)
+	case t.Item != nil:
+		if !w.locationExists(t.Item.Location) || t.Item.Location.ID == t.Item.ID {
+			t.Item.Location = Location{ID: e.Player, Type: LocationPlayer}
+		}
+		w.db.Items[t.Item.ID] = t.Item
+	}
+	delete(w.db.Trash, e.ID)
+	return nil
+}
+
+// locationExists returns true if the given location is still in the world.
+func (w *World) locationExists(loc Location) bool {
+	switch loc.Type {
+	case LocationRoom:
+		return w.db.Rooms[loc.ID] != nil
+	case LocationPlayer:
+		return w.db.Players[loc.ID] != nil
+	case LocationItem:
+		return w.db.Items[loc.ID] != nil
+	}
+	return false
+}
+
+// purgeTrash permanently removes objects that were destroyed before the given time.
+// It returns the number of objects that were purged.
+// It must only be called from WorldThread.
+func (w *World) purgeTrash(before time.Time) int {
+	n := 0
+	for id, t := range w.db.Trash {
+		if t.Destroyed.Before(before) {
+			log.Printf("Purge %s\n", t)
+			delete(w.db.Trash, id)
+			n++
+		}
+	}
+	return n
+}
+
+// FindTrash is a helper method that returns destroyed objects, oldest first.
+// Admins see everything in the trash and other players only see what they own.
+func (c *Connection) FindTrash() []*Trash {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return nil
+	}
+	m := FindTrashMessage{Ack: make(chan []*Trash)}
+	if !c.IsAdmin() {
+		m.Owner = c.Player.ID
+	}
+	c.Server.World.FindTrash <- m
+	return <-m.Ack
+}
+
+// ShowTrash executes the "@trash" command.
+func (c *Connection) ShowTrash() {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	trash := c.FindTrash()
+	if len(trash) == 0 {
+		c.Printf("The trash is empty.\n")
+		return
+	}
+	s := "Trash:\n"
+	for _, t := range trash {
+		s += t.String() + "\n"
+	}
+	if d := c.Server.Config.TrashRetention; d >= 0 {
+		s += fmt.Sprintf("Destroyed objects are purged after %d hours.\n", d)
+	}
+	c.Printf("%s\n", s)
+}
+
+// Undestroy executes the "@undestroy <id>" command, which restores a destroyed object.
+func (c *Connection) Undestroy(s string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	id, err := ParseID(strings.TrimSpace(s))
+	if err != nil {
+		c.Printf("Couldn't parse id: %s\n", s)
+		return
+	}
+	ack := make(chan []*Trash)
+	c.Server.World.FindTrash <- FindTrashMessage{ID: id, Ack: ack}
+	trash := <-ack
+	if len(trash) == 0 {
+		c.Printf("%s isn't in the trash.\n", id)
+		return
+	}
+	o := trash[0].Object()
+	if !c.canEditObject(o, "destroy") {
+		c.Printf("You can't restore %s.\n", o)
+		return
+	}
+	quota := make(map[ObjectType]int)
+	if owner := c.FindPlayerByID(o.ObjectOwner()); owner != nil {
+		for _, t := range []ObjectType{ObjectRoom, ObjectExit, ObjectItem} {
+			quota[t] = c.Quota(owner, t)
+		}
+	}
+	errAck := make(chan error)
+	c.Server.World.Undestroy <- UndestroyMessage{ID: id, Player: c.Player.ID, Quota: quota, Ack: errAck}
+	if err := <-errAck; err != nil {
+		c.Printf("Couldn't restore %s: %s\n", o, err.Error())
+		return
+	}
+	c.Audit("undestroy", id, "", "", o.ObjectName())
+	c.Printf("%s %s Restored\n", o.ObjectType(), o)
+	if o.ObjectType() == ObjectRoom {
+		for _, e := range c.trashedExitsInto(id) {
+			c.Printf("Exit %s in %s led here and is still in the trash. Use @undestroy %s to restore it.\n", e, e.Room, e.ID)
+		}
+	}
+}
+
+// trashedExitsInto returns the exits in the trash that the player can see which lead into the given room.
+// These are usually exits that were destroyed along with the room.
+func (c *Connection) trashedExitsInto(id IDType) []*Exit {
+	r := make([]*Exit, 0)
+	for _, t := range c.FindTrash() {
+		if t.Exit != nil && t.Exit.Destination == id {
+			r = append(r, t.Exit)
+		}
+	}
+	return r
+}
+
+// confirm asks the player a yes or no question.
+func (c *Connection) confirm(question string) bool {
+	c.Printf("%s (yes/no) ", question)
+	a := strings.TrimSpace(strings.ToLower(c.ReadLine()))
+	return a == "y" || a == "yes"
+}
+
+// roomContents counts the exits, players, and items in a room.
+func (c *Connection) roomContents(r *Room) int {
+	n := 0
+	for _, e := range r.Exits {
+		if e != nil {
+			n++
+		}
+	}
+	loc := Location{ID: r.ID, Type: LocationRoom}
+	ack := make(chan []*Player)
+	c.Server.World.FindPlayer <- FindPlayerMessage{Location: &loc, Ack: ack}
+	n += len(<-ack)
+	n += len(c.FindItemsByLocation(loc))
+	return n
+}
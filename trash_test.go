@@ -0,0 +1,95 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	w := NewWorld()
+	owner := w.nextID()
+	r := &Room{ID: w.nextID(), Owner: owner}
+	r.Exits = append(r.Exits, &Exit{ID: w.nextID(), Owner: owner, Room: r.ID})
+	w.db.Rooms[r.ID] = r
+	box := &Item{ID: w.nextID(), Owner: owner, Location: Location{ID: r.ID, Type: LocationRoom}}
+	w.db.Items[box.ID] = box
+
+	delete(w.db.Rooms, r.ID)
+	w.trash(&Trash{DestroyedBy: owner, Room: r, Destroyed: time.Now().Add(-time.Hour)})
+	delete(w.db.Items, box.ID)
+	w.trash(&Trash{DestroyedBy: owner, Item: box})
+
+	trash := w.findTrash(FindTrashMessage{Owner: owner})
+	if len(trash) != 2 || trash[0].Room != r {
+		t.Fatalf("findTrash() returned %d objects, but we expected the room and then the item.", len(trash))
+	}
+	if len(w.findTrash(FindTrashMessage{Owner: w.nextID()})) != 0 {
+		t.Errorf("findTrash() returned objects that belong to somebody else.")
+	}
+
+	player := w.nextID()
+	if err := w.undestroy(UndestroyMessage{ID: box.ID, Player: player}); err != nil {
+		t.Fatalf("undestroy() threw an error: %s", err.Error())
+	}
+	if w.db.Items[box.ID] != box || box.Location != (Location{ID: player, Type: LocationPlayer}) {
+		t.Errorf("The item wasn't given to the player because the room it was in is gone.")
+	}
+	if err := w.undestroy(UndestroyMessage{ID: r.ID, Quota: map[ObjectType]int{ObjectExit: 0}}); err == nil {
+		t.Errorf("undestroy() ignored the owner's exit quota.")
+	}
+
+	if n := w.purgeTrash(time.Now().Add(-time.Minute)); n != 1 {
+		t.Errorf("purgeTrash() purged %d objects, but we expected 1.", n)
+	}
+	if err := w.undestroy(UndestroyMessage{ID: r.ID}); err == nil {
+		t.Errorf("undestroy() restored a purged room.")
+	}
+}
+
+func TestUndestroyRoomExits(t *testing.T) {
+	w := NewWorld()
+	p := &Player{ID: w.nextID()}
+	w.db.Players[p.ID] = p
+	a := &Room{ID: w.nextID(), Owner: p.ID}
+	b := &Room{ID: w.nextID(), Owner: p.ID}
+	w.db.Rooms[a.ID] = a
+	w.db.Rooms[b.ID] = b
+	door := &Exit{ID: w.nextID(), Name: "door", Owner: p.ID, Room: a.ID, Destination: b.ID}
+	a.Exits = append(a.Exits, door)
+	if w.destroyRoom(b.ID, p.ID, 0) == nil {
+		t.Fatalf("destroyRoom() didn't destroy the room.")
+	}
+	c := testConnection(testServer(t, w), p)
+
+	// The door was trashed along with the room, and has to be restored on its own.
+	c.Undestroy(b.ID.String())
+	if c.FindRoomByID(b.ID) == nil {
+		t.Fatalf("Undestroy didn't restore the room.")
+	}
+	if exits := c.trashedExitsInto(b.ID); len(exits) != 1 || exits[0] != door {
+		t.Errorf("trashedExitsInto() = %v, but we expected the door.", exits)
+	}
+	c.Undestroy(door.ID.String())
+	if exits := c.trashedExitsInto(b.ID); len(exits) != 0 || len(a.Exits) != 1 {
+		t.Errorf("Undestroying the door left %v in the trash and %d exits in its room.", exits, len(a.Exits))
+	}
+}
@@ -20,12 +20,33 @@ along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
 package main
 
 import (
+	"fmt"
+	"log"
 	"os"
 
 	"github.com/vaelen/mush"
 )
 
+// dbck checks the saved world state without starting the server.
+func dbck(fix bool) {
+	problems, err := mush.CheckWorldFile(fix)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	fmt.Printf("Found %d problems.\n", len(problems))
+	if len(problems) > 0 && !fix {
+		os.Exit(1)
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "dbck" {
+		dbck(len(os.Args) > 2 && os.Args[2] == "fix")
+		return
+	}
 	addr := ":2222"
 	tlsAddr := ":2223"
 	if len(os.Args) > 1 {
@@ -0,0 +1,219 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"strings"
+	"time"
+)
+
+// walk is a walk that a player is taking.
+// Closing stop ends the walk, and done is closed once the walk is over.
+type walk struct {
+	stop chan bool
+	done chan bool
+}
+
+// findPath returns the shortest list of exits that leads from the start room to the goal room.
+// It returns nil if the goal can't be reached, and an empty list if the start room is the goal.
+// find returns a room by its ID, and usable returns true if an exit leading to a room can be used.
+func findPath(start *Room, goal IDType, find func(IDType) *Room, usable func(*Exit, *Room) bool) []*Exit {
+	if start.ID == goal {
+		return make([]*Exit, 0)
+	}
+	via := map[IDType]*Exit{start.ID: nil}
+	queue := []*Room{start}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+		for _, e := range r.Exits {
+			if e == nil {
+				continue
+			}
+			if _, seen := via[e.Destination]; seen {
+				continue
+			}
+			dest := find(e.Destination)
+			if dest == nil || !usable(e, dest) {
+				continue
+			}
+			via[dest.ID] = e
+			if dest.ID == goal {
+				path := make([]*Exit, 0)
+				for x := e; x != nil; x = via[x.Room] {
+					path = append([]*Exit{x}, path...)
+				}
+				return path
+			}
+			queue = append(queue, dest)
+		}
+	}
+	return nil
+}
+
+// findRoom finds a room by ID or name. "home" is the player's home.
+func (c *Connection) findRoom(s string) *Room {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "home") {
+		return c.FindRoomByID(c.playerHome(c.Player).ID)
+	}
+	if id, err := ParseID(s); err == nil {
+		r := c.FindRoomByID(id)
+		if r == nil {
+			c.Printf("%s is not a room.\n", id)
+		}
+		return r
+	}
+	found := make([]*Room, 0)
+	for _, r := range c.FindAllRooms() {
+		if strings.EqualFold(r.Name, s) {
+			found = append(found, r)
+		}
+	}
+	switch len(found) {
+	case 0:
+		c.Printf("There isn't a room called %s.\n", s)
+	case 1:
+		return found[0]
+	default:
+		c.Printf("Which room did you mean?\n")
+		for _, r := range found {
+			c.Printf("%s\n", r)
+		}
+	}
+	return nil
+}
+
+// pathTo finds the shortest path from the player's room to the given room.
+// Only exits that the player can see and get through are used.
+func (c *Connection) pathTo(target string) []*Exit {
+	if c.Player.Location.Type != LocationRoom {
+		c.Printf("You need to be in a room.\n")
+		return nil
+	}
+	here := c.FindRoomByID(c.Player.Location.ID)
+	if here == nil {
+		c.Printf("You're Lost!\n")
+		return nil
+	}
+	goal := c.findRoom(target)
+	if goal == nil {
+		return nil
+	}
+	usable := func(e *Exit, dest *Room) bool {
+		return c.CanSeeExit(e) && !e.Locked && c.evalLock(e.Lock.Expression) && c.evalLock(dest.Lock.Expression)
+	}
+	path := findPath(here, goal.ID, c.FindRoomByID, usable)
+	if path == nil {
+		c.Printf("You don't know a way to %s.\n", goal.Name)
+		return nil
+	}
+	if len(path) == 0 {
+		c.Printf("You're already there.\n")
+		return nil
+	}
+	return path
+}
+
+// ShowPath executes the "path <room>" command.
+func (c *Connection) ShowPath(target string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	path := c.pathTo(target)
+	if path == nil {
+		return
+	}
+	names := make([]string, 0, len(path))
+	for _, e := range path {
+		names = append(names, e.Name)
+	}
+	c.Printf("%s (%d steps)\n", strings.Join(names, ", "), len(path))
+}
+
+// Walk executes the "walk <room>" command, which moves the player along the shortest path to a room.
+// The player takes one step each WalkDelay, and stops as soon as they type anything else.
+func (c *Connection) Walk(target string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	path := c.pathTo(target)
+	if path == nil {
+		return
+	}
+	delay := time.Second
+	if c.Server != nil && c.Server.Config != nil {
+		delay = time.Duration(c.Server.Config.WalkDelay) * time.Millisecond
+	}
+	w := &walk{stop: make(chan bool), done: make(chan bool)}
+	c.walkLock.Lock()
+	c.walking = w
+	c.walkLock.Unlock()
+	c.Printf("You start walking. (%d steps)\n", len(path))
+	go func() {
+		defer c.finishWalking(w)
+		for i, e := range path {
+			if i > 0 {
+				select {
+				case <-w.stop:
+					return
+				case <-time.After(delay):
+				}
+			}
+			select {
+			case <-w.stop:
+				return
+			default:
+			}
+			if c.Player.Location != (Location{ID: e.Room, Type: LocationRoom}) || !c.useExit(e) {
+				c.Printf("You can't go any further.\n")
+				return
+			}
+		}
+		c.Printf("You have arrived.\n")
+	}()
+}
+
+// finishWalking forgets a walk once it is over.
+func (c *Connection) finishWalking(w *walk) {
+	c.walkLock.Lock()
+	defer c.walkLock.Unlock()
+	if c.walking == w {
+		c.walking = nil
+	}
+	close(w.done)
+}
+
+// StopWalking stops the player if they are walking somewhere.
+// It waits for the step that the player is taking to finish, so the walk can't move the player after it returns.
+func (c *Connection) StopWalking() {
+	if c == nil {
+		return
+	}
+	c.walkLock.Lock()
+	w := c.walking
+	c.walking = nil
+	c.walkLock.Unlock()
+	if w != nil {
+		close(w.stop)
+		<-w.done
+		c.Printf("You stop walking.\n")
+	}
+}
@@ -0,0 +1,89 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFindPath(t *testing.T) {
+	rooms := make(map[IDType]*Room)
+	for id := IDType(1); id <= 4; id++ {
+		rooms[id] = &Room{ID: id}
+	}
+	link := func(from IDType, name string, to IDType) *Exit {
+		e := &Exit{ID: from*10 + IDType(len(rooms[from].Exits)), Name: name, Room: from, Destination: to}
+		rooms[from].Exits = append(rooms[from].Exits, e)
+		return e
+	}
+	link(1, "east", 2)
+	link(2, "east", 3)
+	door := link(1, "door", 3)
+	link(3, "north", 4)
+	find := func(id IDType) *Room { return rooms[id] }
+	all := func(e *Exit, r *Room) bool { return true }
+
+	path := findPath(rooms[1], 4, find, all)
+	if len(path) != 2 || path[0] != door || path[1].Destination != 4 {
+		t.Errorf("findPath() = %v, but we expected door, north.", path)
+	}
+	path = findPath(rooms[1], 4, find, func(e *Exit, r *Room) bool { return e != door })
+	if len(path) != 3 {
+		t.Errorf("findPath() returned %d steps, but we expected 3 without the door.", len(path))
+	}
+	if path := findPath(rooms[4], 1, find, all); path != nil {
+		t.Errorf("findPath() found a way back that doesn't exist.")
+	}
+	if path := findPath(rooms[2], 2, find, all); path == nil || len(path) != 0 {
+		t.Errorf("findPath() didn't return an empty path to the start room.")
+	}
+}
+
+func TestStopWalking(t *testing.T) {
+	w := NewWorld()
+	hall := w.db.Rooms[w.db.DefaultRoom]
+	rooms := []*Room{hall}
+	for i := 0; i < 3; i++ {
+		r := &Room{ID: w.nextID(), Name: fmt.Sprintf("Room %d", i+1)}
+		w.db.Rooms[r.ID] = r
+		prev := rooms[len(rooms)-1]
+		prev.Exits = append(prev.Exits, &Exit{ID: w.nextID(), Name: "east", Room: prev.ID, Destination: r.ID})
+		rooms = append(rooms, r)
+	}
+	p := &Player{ID: w.nextID(), Location: Location{ID: hall.ID, Type: LocationRoom}}
+	w.db.Players[p.ID] = p
+	s := testServer(t, w)
+	s.Config.WalkDelay = 1
+	c := testConnection(s, p)
+
+	c.Walk(rooms[3].ID.String())
+	c.StopWalking()
+	// StopWalking waits for the walk to end, so the player can't be moved after it returns.
+	stopped := p.Location
+	time.Sleep(20 * time.Millisecond)
+	if p.Location != stopped {
+		t.Errorf("The player walked from %s to %s after they stopped.", stopped, p.Location)
+	}
+	if c.walking != nil {
+		t.Errorf("The walk wasn't forgotten after it was stopped.")
+	}
+}
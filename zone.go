@@ -0,0 +1,610 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Zone groups rooms that share an owner and settings.
+//
+// The owner and Builders of a zone can edit every room in it.
+// DescriptionPrefix is shown before the description of each room in the zone,
+// and one of the Ambient messages is shown to the players in the zone from time to time.
+// Flags are added to the flags of each room in the zone.
+type Zone struct {
+	ID                IDType
+	Name              string
+	Owner             IDType
+	Builders          []IDType
+	DescriptionPrefix string
+	Ambient           []string
+	Flags             Flags
+}
+
+func (z *Zone) String() string {
+	if z == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s [%s]", z.Name, z.ID)
+}
+
+// FindZoneMessage is sent to FindZone to find zones.
+// If neither ID nor Name is set, every zone is returned.
+type FindZoneMessage struct {
+	ID   IDType
+	Name string
+	Ack  chan []*Zone
+}
+
+// NewZoneMessage is sent to NewZone to create a new zone. Zone names must be unique.
+type NewZoneMessage struct {
+	Name  string
+	Owner IDType
+	Ack   chan *Zone
+}
+
+// TransferZoneMessage is sent to TransferZone to give a zone, and the rooms and exits in it, to a new owner.
+// Only rooms and exits that belong to the zone's old owner are given away, and they must fit within the new owner's Quota.
+type TransferZoneMessage struct {
+	Zone  IDType
+	Owner IDType
+	Quota map[ObjectType]int
+	Ack   chan TransferZoneReply
+}
+
+// TransferZoneReply holds the number of rooms and exits that were given away, or the reason that the zone wasn't.
+type TransferZoneReply struct {
+	Given int
+	Err   error
+}
+
+// findZones returns the zones that match a FindZoneMessage, ordered by ID.
+// It must only be called from WorldThread.
+func (w *World) findZones(e FindZoneMessage) []*Zone {
+	r := make([]*Zone, 0)
+	for id, z := range w.db.Zones {
+		if (e.ID > 0 && id != e.ID) || (e.Name != "" && !strings.EqualFold(z.Name, strings.TrimSpace(e.Name))) {
+			continue
+		}
+		r = append(r, z)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].ID < r[j].ID })
+	return r
+}
+
+// newZone creates a zone, or returns nil if a zone with that name already exists.
+// It must only be called from WorldThread.
+func (w *World) newZone(e NewZoneMessage) *Zone {
+	name := strings.TrimSpace(e.Name)
+	if name == "" || len(w.findZones(FindZoneMessage{Name: name})) > 0 {
+		return nil
+	}
+	log.Printf("New Zone: %s\n", name)
+	z := &Zone{ID: w.nextID(), Name: name, Owner: e.Owner}
+	w.db.Zones[z.ID] = z
+	return z
+}
+
+// transferZone gives a zone, and the rooms and exits in it that belong to the zone's old owner, to a new owner.
+// Rooms and exits that builders own in the zone are left alone.
+// It must only be called from WorldThread.
+func (w *World) transferZone(e TransferZoneMessage) TransferZoneReply {
+	z := w.db.Zones[e.Zone]
+	if z == nil {
+		return TransferZoneReply{Err: fmt.Errorf("%s isn't a zone", e.Zone)}
+	}
+	rooms := make([]*Room, 0)
+	exits := make([]*Exit, 0)
+	for _, r := range w.findRoomByZone(z.ID) {
+		if r.Owner == z.Owner {
+			rooms = append(rooms, r)
+		}
+		for _, x := range r.Exits {
+			if x != nil && x.Owner == z.Owner {
+				exits = append(exits, x)
+			}
+		}
+	}
+	need := map[ObjectType]int{ObjectRoom: len(rooms), ObjectExit: len(exits)}
+	if e.Owner != z.Owner && !w.withinQuota(e.Owner, e.Quota, need) {
+		return TransferZoneReply{Err: fmt.Errorf("it would put the new owner over their quota")}
+	}
+	z.Owner = e.Owner
+	for _, r := range rooms {
+		r.Owner = e.Owner
+	}
+	for _, x := range exits {
+		x.Owner = e.Owner
+	}
+	return TransferZoneReply{Given: len(rooms) + len(exits)}
+}
+
+// findRoomByZone returns the rooms in a zone.
+// It must only be called from WorldThread.
+func (w *World) findRoomByZone(id IDType) []*Room {
+	r := make([]*Room, 0)
+	for _, v := range w.db.Rooms {
+		if v.Zone == id {
+			r = append(r, v)
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].ID < r[j].ID })
+	return r
+}
+
+// FindZones is a helper method that returns zones by ID or name, or every zone if both are empty.
+func (c *Connection) FindZones(id IDType, name string) []*Zone {
+	ack := make(chan []*Zone)
+	c.Server.World.FindZone <- FindZoneMessage{ID: id, Name: name, Ack: ack}
+	return <-ack
+}
+
+// FindZoneByID is a helper method that returns a zone based on its ID.
+func (c *Connection) FindZoneByID(id IDType) *Zone {
+	if id == 0 {
+		return nil
+	}
+	zones := c.FindZones(id, "")
+	if len(zones) == 0 {
+		return nil
+	}
+	return zones[0]
+}
+
+// FindRoomsByZone is a helper method that returns the rooms in a zone.
+func (c *Connection) FindRoomsByZone(id IDType) []*Room {
+	ack := make(chan []*Room)
+	c.Server.World.FindRoom <- FindRoomMessage{Zone: id, Ack: ack}
+	return <-ack
+}
+
+// findZone finds a zone by ID or name.
+func (c *Connection) findZone(s string) *Zone {
+	s = strings.TrimSpace(s)
+	var zones []*Zone
+	if id, err := ParseID(s); err == nil {
+		zones = c.FindZones(id, "")
+	} else {
+		zones = c.FindZones(0, s)
+	}
+	if len(zones) == 0 {
+		c.Printf("There isn't a zone called %s.\n", s)
+		return nil
+	}
+	return zones[0]
+}
+
+// zoneOf returns the zone that a room is in, or nil if it isn't in one.
+func (c *Connection) zoneOf(r *Room) *Zone {
+	if r == nil {
+		return nil
+	}
+	return c.FindZoneByID(r.Zone)
+}
+
+// roomFlags returns the flags of a room, including the flags of its zone.
+func (c *Connection) roomFlags(r *Room) Flags {
+	if z := c.zoneOf(r); z != nil {
+		return r.Flags | z.Flags
+	}
+	return r.Flags
+}
+
+// isZoneBuilder returns true if the player owns the zone or is one of its builders.
+func (c *Connection) isZoneBuilder(z *Zone) bool {
+	if z == nil {
+		return false
+	}
+	if z.Owner == c.Player.ID {
+		return true
+	}
+	for _, id := range z.Builders {
+		if id == c.Player.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// canEditZone returns true if the player can change a zone's settings.
+// Zones can be changed by their owner and by staff.
+func (c *Connection) canEditZone(z *Zone) bool {
+	return z != nil && c.Role() >= RolePlayer && (z.Owner == c.Player.ID || c.Role() >= RoleStaff)
+}
+
+// canBuildInZone returns true if the player can add rooms to a zone.
+func (c *Connection) canBuildInZone(z *Zone) bool {
+	return z != nil && c.Role() >= RolePlayer && (c.isZoneBuilder(z) || c.Role() >= RoleStaff)
+}
+
+// setZone puts a room into a zone. An ID of 0 takes the room out of its zone.
+// Only the zone's builders can add rooms to it, but anybody who can edit a room can take it out of its zone,
+// so that a zone's owner can't keep other players' rooms in their zone.
+func (c *Connection) setZone(r *Room, value string) bool {
+	id, err := ParseID(value)
+	if err != nil {
+		c.Printf("Zone must be an ID value of the form '@0'.\n")
+		return false
+	}
+	if id == 0 {
+		r.Zone = 0
+		return true
+	}
+	z := c.FindZoneByID(id)
+	if z == nil {
+		c.Printf("%s is not a zone.\n", id)
+		return false
+	}
+	if !c.canBuildInZone(z) {
+		c.Printf("You can't build in %s.\n", z)
+		return false
+	}
+	r.Zone = id
+	return true
+}
+
+// ambientMessage returns a random ambient message from a zone.
+func (z *Zone) ambientMessage() string {
+	if len(z.Ambient) == 0 {
+		return ""
+	}
+	return z.Ambient[rand.Intn(len(z.Ambient))]
+}
+
+// AmbientThread shows the ambient messages of each zone to the players in it until stop is closed.
+func (s *Server) AmbientThread(stop chan bool) {
+	if s.Config.AmbientFrequency <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(s.Config.AmbientFrequency) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, conn := range s.PlayerConnections() {
+				if conn.Player == nil || !conn.Authenticated || conn.Player.Location.Type != LocationRoom {
+					continue
+				}
+				if z := conn.zoneOf(conn.FindRoomByID(conn.Player.Location.ID)); z != nil {
+					if m := z.ambientMessage(); m != "" {
+						conn.Printf("%s\n", m)
+					}
+				}
+			}
+		}
+	}
+}
+
+// RoomInfo describes a room in the form used by the GMCP Room.Info message.
+type RoomInfo struct {
+	Num   IDType            `json:"num"`
+	Name  string            `json:"name"`
+	Zone  string            `json:"zone"`
+	Exits map[string]IDType `json:"exits"`
+}
+
+// roomInfo returns the GMCP-style description of a room, showing only the exits that the player can see.
+func (c *Connection) roomInfo(r *Room) RoomInfo {
+	info := RoomInfo{Num: r.ID, Name: r.Name, Exits: make(map[string]IDType)}
+	if z := c.zoneOf(r); z != nil {
+		info.Zone = z.Name
+	}
+	for _, e := range r.Exits {
+		if e != nil && c.CanSeeExit(e) && c.canSeeDark(e) {
+			info.Exits[strings.ToLower(e.Name)] = e.Destination
+		}
+	}
+	return info
+}
+
+// ShowRoomInfo executes the "roominfo" command, which prints a GMCP-style Room.Info message for the player's room.
+func (c *Connection) ShowRoomInfo() {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	r := c.exitRoom()
+	if r == nil {
+		c.Printf("You need to be in a room.\n")
+		return
+	}
+	b, err := json.Marshal(c.roomInfo(r))
+	if err != nil {
+		c.Printf("Error: %s\n", err.Error())
+		return
+	}
+	c.Printf("Room.Info %s\n", b)
+}
+
+// showZone describes a zone for the "@zone show" command.
+func (c *Connection) showZone(z *Zone) string {
+	f := "%15s : %s\n"
+	s := ""
+	s += fmt.Sprintf(f, "ID", z.ID)
+	s += fmt.Sprintf("%15s : %q\n", "Name", z.Name)
+	s += fmt.Sprintf(f, "Owner", z.Owner)
+	s += fmt.Sprintf(f, "Builders", showIDs(z.Builders))
+	s += fmt.Sprintf("%15s : %q\n", "Prefix", z.DescriptionPrefix)
+	s += fmt.Sprintf(f, "Flags", z.Flags)
+	for i, m := range z.Ambient {
+		s += fmt.Sprintf("%15s : %q\n", fmt.Sprintf("Ambient %d", i+1), m)
+	}
+	rooms := c.FindRoomsByZone(z.ID)
+	s += fmt.Sprintf(f, "Rooms", fmt.Sprintf("%d", len(rooms)))
+	for _, r := range rooms {
+		s += fmt.Sprintf("                  %s\n", r)
+	}
+	return s
+}
+
+// ZoneCommand executes the "@zone" command.
+func (c *Connection) ZoneCommand(args []string) {
+	if c == nil || !c.Authenticated || c.Player == nil {
+		return
+	}
+	if len(args) == 0 {
+		c.Printf("Usage: @zone <list|show|create|set|builder|ambient|assign|chown|export> ...\n")
+		return
+	}
+	rest := strings.TrimSpace(strings.Join(args[1:], " "))
+	switch strings.ToLower(args[0]) {
+	case "list":
+		zones := c.FindZones(0, "")
+		if len(zones) == 0 {
+			c.Printf("There aren't any zones.\n")
+			return
+		}
+		s := "Zones:\n"
+		for _, z := range zones {
+			s += fmt.Sprintf("%s, owned by %s, %d rooms\n", z, z.Owner, len(c.FindRoomsByZone(z.ID)))
+		}
+		c.Printf("%s\n", s)
+	case "show":
+		if z := c.findZone(rest); z != nil {
+			c.Printf("%s\n", c.showZone(z))
+		}
+	case "create":
+		c.createZone(rest)
+	case "set":
+		c.setZoneField(rest)
+	case "builder":
+		c.zoneBuilder(args[1:])
+	case "ambient":
+		c.zoneAmbient(args[1:])
+	case "assign":
+		parts := strings.SplitN(rest, "=", 2)
+		if len(parts) < 2 {
+			c.Printf("Usage: @zone assign <room>=<zone|none>\n")
+			return
+		}
+		r, ok := c.resolve(parts[0]).(*Room)
+		if !ok {
+			c.Printf("You can only put rooms into zones.\n")
+			return
+		}
+		id := IDType(0)
+		if !strings.EqualFold(strings.TrimSpace(parts[1]), "none") {
+			z := c.findZone(parts[1])
+			if z == nil {
+				return
+			}
+			id = z.ID
+		}
+		c.setThing(r, "zone", id.String())
+	case "chown":
+		c.transferZone(rest)
+	case "export":
+		z := c.findZone(rest)
+		if z == nil {
+			return
+		}
+		if !c.canEditZone(z) {
+			c.Printf("You can't export %s.\n", z)
+			return
+		}
+		c.Printf("%s", dotGraph(z.Name, c.FindRoomsByZone(z.ID)))
+	default:
+		c.Printf("Usage: @zone <list|show|create|set|builder|ambient|assign|chown|export> ...\n")
+	}
+}
+
+// createZone executes "@zone create <name>".
+func (c *Connection) createZone(name string) {
+	if c.Role() < RoleBuilder {
+		c.Printf("You don't have permission to create zones.\n")
+		return
+	}
+	if name == "" {
+		c.Printf("You need to give the zone a name.\n")
+		return
+	}
+	ack := make(chan *Zone)
+	c.Server.World.NewZone <- NewZoneMessage{Name: name, Owner: c.Player.ID, Ack: ack}
+	z := <-ack
+	if z == nil {
+		c.Printf("Couldn't Create Zone\n")
+		return
+	}
+	c.Audit("create", z.ID, "", "", z.Name)
+	c.Printf("New Zone Created: %s\n", z)
+}
+
+// setZoneField executes "@zone set <zone> <field>=<value>".
+func (c *Connection) setZoneField(args string) {
+	parts := strings.SplitN(args, "=", 2)
+	words := strings.Fields(parts[0])
+	if len(parts) < 2 || len(words) < 2 {
+		c.Printf("Usage: @zone set <zone> <name|prefix|flags>=<value>\n")
+		return
+	}
+	z := c.findZone(strings.Join(words[:len(words)-1], " "))
+	if z == nil {
+		return
+	}
+	if !c.canEditZone(z) {
+		c.Printf("You can't change %s.\n", z)
+		return
+	}
+	field := strings.ToLower(words[len(words)-1])
+	value := strings.TrimSpace(parts[1])
+	var old string
+	switch field {
+	case "name":
+		if value == "" {
+			c.Printf("You need to give the zone a name.\n")
+			return
+		}
+		if len(c.FindZones(0, value)) > 0 {
+			c.Printf("There is already a zone called %s.\n", value)
+			return
+		}
+		old = z.Name
+		z.Name = value
+	case "prefix":
+		old = z.DescriptionPrefix
+		z.DescriptionPrefix = value
+	case "flags":
+		f, err := ParseFlags(value)
+		if err != nil {
+			c.Printf("Error: %s\n", err.Error())
+			return
+		}
+		if f&wizardFlags != 0 {
+			c.Printf("Zones can't have %s.\n", f&wizardFlags)
+			return
+		}
+		old = z.Flags.String()
+		z.Flags = f
+		value = f.String()
+	default:
+		c.Printf("Fields: name, prefix, flags\n")
+		return
+	}
+	c.Audit("set", z.ID, field, old, value)
+	c.Printf("Set.\n")
+}
+
+// zoneBuilder executes "@zone builder <zone> <add|remove> <player>".
+func (c *Connection) zoneBuilder(args []string) {
+	if len(args) < 3 {
+		c.Printf("Usage: @zone builder <zone> <add|remove> <player>\n")
+		return
+	}
+	z := c.findZone(args[0])
+	if z == nil {
+		return
+	}
+	if !c.canEditZone(z) {
+		c.Printf("You can't change %s.\n", z)
+		return
+	}
+	p := c.lookupPlayer(strings.Join(args[2:], " "))
+	if p == nil {
+		c.Printf("%s is not a player.\n", strings.Join(args[2:], " "))
+		return
+	}
+	old := showIDs(z.Builders)
+	builders := removeID(z.Builders, p.ID)
+	switch strings.ToLower(args[1]) {
+	case "add":
+		builders = append(builders, p.ID)
+		c.Printf("%s can now build in %s.\n", p, z)
+	case "remove":
+		c.Printf("%s can no longer build in %s.\n", p, z)
+	default:
+		c.Printf("Usage: @zone builder <zone> <add|remove> <player>\n")
+		return
+	}
+	z.Builders = builders
+	c.Audit("set", z.ID, "builders", old, showIDs(z.Builders))
+}
+
+// zoneAmbient executes "@zone ambient <zone> <add <message>|clear>".
+func (c *Connection) zoneAmbient(args []string) {
+	if len(args) < 2 {
+		c.Printf("Usage: @zone ambient <zone> <add <message>|clear>\n")
+		return
+	}
+	z := c.findZone(args[0])
+	if z == nil {
+		return
+	}
+	if !c.canEditZone(z) {
+		c.Printf("You can't change %s.\n", z)
+		return
+	}
+	switch strings.ToLower(args[1]) {
+	case "add":
+		m := strings.TrimSpace(strings.Join(args[2:], " "))
+		if m == "" {
+			c.Printf("Usage: @zone ambient <zone> add <message>\n")
+			return
+		}
+		z.Ambient = append(z.Ambient, m)
+		c.Audit("set", z.ID, "ambient", "", m)
+	case "clear":
+		z.Ambient = nil
+		c.Audit("set", z.ID, "ambient", "", "")
+	default:
+		c.Printf("Usage: @zone ambient <zone> <add <message>|clear>\n")
+		return
+	}
+	c.Printf("Set.\n")
+}
+
+// transferZone executes "@zone chown <zone>=<player>".
+func (c *Connection) transferZone(args string) {
+	parts := strings.SplitN(args, "=", 2)
+	if len(parts) < 2 {
+		c.Printf("Usage: @zone chown <zone>=<player>\n")
+		return
+	}
+	z := c.findZone(parts[0])
+	if z == nil {
+		return
+	}
+	if (z.Owner != c.Player.ID && c.Role() < RoleWizard) || c.Role() < fieldRole("owner") {
+		c.Printf("You can't give away %s.\n", z)
+		return
+	}
+	p := c.lookupPlayer(parts[1])
+	if p == nil {
+		c.Printf("%s is not a player.\n", strings.TrimSpace(parts[1]))
+		return
+	}
+	old := z.Owner
+	ack := make(chan TransferZoneReply)
+	c.Server.World.TransferZone <- TransferZoneMessage{Zone: z.ID, Owner: p.ID, Quota: c.quotas(p), Ack: ack}
+	r := <-ack
+	if r.Err != nil {
+		c.Printf("Couldn't give away %s: %s\n", z, r.Err.Error())
+		return
+	}
+	c.Audit("chown", z.ID, "owner", old.String(), p.ID.String())
+	c.Printf("Gave %s and %d rooms and exits to %s.\n", z, r.Given, p)
+}
@@ -0,0 +1,113 @@
+/******
+This file is part of Vaelen/MUSH.
+
+Copyright 2017, Andrew Young <andrew@vaelen.org>
+
+    Vaelen/MUSH is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+    Vaelen/MUSH is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+along with Vaelen/MUSH.  If not, see <http://www.gnu.org/licenses/>.
+******/
+
+package mush
+
+import (
+	"testing"
+)
+
+func TestZones(t *testing.T) {
+	w := NewWorld()
+	owner := w.nextID()
+	z := w.newZone(NewZoneMessage{Name: "Old Town", Owner: owner})
+	if z == nil {
+		t.Fatalf("newZone() didn't create the zone.")
+	}
+	if w.newZone(NewZoneMessage{Name: "old town"}) != nil {
+		t.Errorf("newZone() created a second zone with the same name.")
+	}
+	if zones := w.findZones(FindZoneMessage{Name: "OLD TOWN"}); len(zones) != 1 || zones[0] != z {
+		t.Errorf("findZones() didn't find the zone by name.")
+	}
+
+	r := &Room{ID: w.nextID(), Owner: owner, Zone: z.ID}
+	r.Exits = append(r.Exits, &Exit{ID: w.nextID(), Owner: owner, Room: r.ID})
+	w.db.Rooms[r.ID] = r
+	if rooms := w.findRoomByZone(z.ID); len(rooms) != 1 || rooms[0] != r {
+		t.Errorf("findRoomByZone() didn't find the room in the zone.")
+	}
+
+	builder := w.nextID()
+	shop := &Room{ID: w.nextID(), Owner: builder, Zone: z.ID}
+	shop.Exits = append(shop.Exits, &Exit{ID: w.nextID(), Owner: owner, Room: shop.ID})
+	w.db.Rooms[shop.ID] = shop
+
+	heir := w.nextID()
+	full := map[ObjectType]int{ObjectRoom: 0, ObjectExit: 5}
+	if x := w.transferZone(TransferZoneMessage{Zone: z.ID, Owner: heir, Quota: full}); x.Err == nil {
+		t.Errorf("transferZone() gave away a room to somebody with a room quota of 0.")
+	}
+	if z.Owner != owner || r.Owner != owner {
+		t.Errorf("transferZone() gave away the zone even though it was over quota.")
+	}
+	if x := w.transferZone(TransferZoneMessage{Zone: z.ID, Owner: heir}); x.Err != nil || x.Given != 3 {
+		t.Errorf("transferZone() gave away %d rooms and exits, %v, but we expected 3.", x.Given, x.Err)
+	}
+	if z.Owner != heir || r.Owner != heir || r.Exits[0].Owner != heir || shop.Exits[0].Owner != heir {
+		t.Errorf("transferZone() didn't give the zone, rooms, and exits to the new owner.")
+	}
+	if shop.Owner != builder {
+		t.Errorf("transferZone() gave away a room that belongs to a builder.")
+	}
+
+	delete(w.db.Zones, z.ID)
+	w.db.Check(true)
+	if r.Zone != 0 {
+		t.Errorf("Check(true) didn't take the room out of a zone that doesn't exist.")
+	}
+}
+
+func TestZoneFields(t *testing.T) {
+	w := NewWorld()
+	p := &Player{ID: w.nextID(), Role: RoleBuilder}
+	other := &Player{ID: w.nextID(), Role: RoleBuilder}
+	w.db.Players[p.ID] = p
+	w.db.Players[other.ID] = other
+	town := w.newZone(NewZoneMessage{Name: "Old Town", Owner: p.ID})
+	forest := w.newZone(NewZoneMessage{Name: "Forest", Owner: other.ID})
+	r := &Room{ID: w.nextID(), Owner: p.ID, Zone: forest.ID}
+	w.db.Rooms[r.ID] = r
+	c := testConnection(testServer(t, w), p)
+
+	for _, x := range []struct {
+		args string
+		name string
+	}{
+		{"Old Town name=", "Old Town"},
+		{"Old Town name=forest", "Old Town"},
+		{"Old Town name=New Town", "New Town"},
+	} {
+		c.setZoneField(x.args)
+		if town.Name != x.name {
+			t.Errorf("After @zone set %s, the zone is called %q, but we expected %q.", x.args, town.Name, x.name)
+		}
+	}
+
+	// The player isn't one of the forest's builders, but they can still take their own room out of it.
+	if !c.setZone(r, "@0") || r.Zone != 0 {
+		t.Errorf("The room's owner couldn't take it out of another player's zone.")
+	}
+	if c.setZone(r, forest.ID.String()) || r.Zone != 0 {
+		t.Errorf("A player who isn't one of the zone's builders put a room into it.")
+	}
+	if !c.setZone(r, town.ID.String()) || r.Zone != town.ID {
+		t.Errorf("The zone's owner couldn't put a room into it.")
+	}
+}